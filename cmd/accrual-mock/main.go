@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/casnerano/yandex-gophermart/internal/accrualmock"
+	srv "github.com/casnerano/yandex-gophermart/internal/server"
+	log "github.com/casnerano/yandex-gophermart/pkg/logger"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
+
+const defaultScript = "REGISTERED:1s,PROCESSING:2s,PROCESSED"
+
+func main() {
+
+	// Init configuration
+	address := os.Getenv("RUN_ADDRESS")
+	if address == "" {
+		address = ":8080"
+	}
+
+	options := accrualmock.Options{}
+	var rawScript, rawAutoAccrual string
+
+	flag.StringVar(&address, "a", address, "Server address")
+	flag.StringVar(&rawScript, "script", defaultScript, "Order status progression, e.g. \"REGISTERED:1s,PROCESSING:2s,PROCESSED\"")
+	flag.StringVar(&rawAutoAccrual, "auto-accrual", "0", "Accrual for orders requested without registration, zero disables auto registration")
+	flag.DurationVar(&options.Latency, "latency", 0, "Response latency")
+	flag.DurationVar(&options.Jitter, "jitter", 0, "Random addition to response latency")
+	flag.IntVar(&options.RequestsPerMinute, "rpm", 0, "Order status requests per minute limit, zero disables throttling")
+	flag.Float64Var(&options.FaultRate, "fault-rate", 0, "Share of requests answered with the fault status, from 0 to 1")
+	flag.IntVar(&options.FaultStatus, "fault-status", http.StatusInternalServerError, "Fault response status")
+	flag.Parse()
+
+	script, err := accrualmock.ParseScript(rawScript)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	autoAccrual, err := money.Parse(rawAutoAccrual)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// Init logger
+	logger := log.New()
+	defer func() {
+		if err = logger.Close(); err != nil {
+			fmt.Println(err)
+		}
+	}()
+
+	logger.AddHandler(
+		log.NewStdOutHandler(
+			log.NewTextFormatter(),
+			log.LogLevelDebug,
+			true,
+		),
+	)
+
+	simulator := accrualmock.NewSimulator(script, autoAccrual)
+	router := accrualmock.NewServer(simulator, options, logger).Router()
+
+	// Starting server and wait signal for graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	server := srv.New(address, router, logger)
+	if err = server.Run(ctx); err != nil {
+		logger.Critical("Failed running server", err)
+		os.Exit(1)
+	}
+}
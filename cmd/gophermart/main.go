@@ -6,18 +6,29 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
-	"github.com/go-resty/resty/v2"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	cfg "github.com/casnerano/yandex-gophermart/internal/config"
+	"github.com/casnerano/yandex-gophermart/internal/model"
 	"github.com/casnerano/yandex-gophermart/internal/repository/pgsql"
 	srv "github.com/casnerano/yandex-gophermart/internal/server"
 	"github.com/casnerano/yandex-gophermart/internal/service/account"
 	"github.com/casnerano/yandex-gophermart/internal/service/accrual"
 	"github.com/casnerano/yandex-gophermart/internal/service/balance"
+	"github.com/casnerano/yandex-gophermart/internal/service/deadletter"
+	"github.com/casnerano/yandex-gophermart/internal/service/events"
+	"github.com/casnerano/yandex-gophermart/internal/service/health"
+	"github.com/casnerano/yandex-gophermart/internal/service/idempotency"
+	"github.com/casnerano/yandex-gophermart/internal/service/ledger"
+	"github.com/casnerano/yandex-gophermart/internal/service/metrics"
+	"github.com/casnerano/yandex-gophermart/internal/service/notification"
 	"github.com/casnerano/yandex-gophermart/internal/service/order"
+	"github.com/casnerano/yandex-gophermart/internal/service/outbox"
 	"github.com/casnerano/yandex-gophermart/internal/service/queue"
+	"github.com/casnerano/yandex-gophermart/internal/service/reconciliation"
 	"github.com/casnerano/yandex-gophermart/internal/service/withdraw"
 	log "github.com/casnerano/yandex-gophermart/pkg/logger"
 )
@@ -60,41 +71,199 @@ func main() {
 
 	defer connection.Close()
 
-	// Rabbitmq connection
-	rabbitmq, err := queue.NewRabbitMQ(config.Accrual.Queue.DSN, "accrual", "accrual")
-	if err != nil {
-		logger.Alert("Failed initialization rabbitmq", err)
+	// Accrual queue
+	accrualBatching := accrual.Batching{
+		Size:   config.Accrual.Batch.Size,
+		Window: time.Duration(config.Accrual.Batch.WindowMS) * time.Millisecond,
+	}
+
+	var (
+		accrualQueue  queue.Queue
+		orderAddHooks []pgsql.OrderAddHook
+	)
+	switch config.Accrual.Queue.Driver {
+	case queue.DriverMemory:
+		accrualQueue = queue.NewMemory(config.Accrual.Queue.BufferSize, config.Accrual.Queue.MaxRetries)
+	case queue.DriverPostgres:
+		// Блокировка задания не должна истечь, пока обработчик еще его выполняет
+		visibilityTimeout := time.Duration(config.Accrual.Queue.Postgres.VisibilityTimeout) * time.Second
+		jobTimeout := accrual.JobTimeout(time.Duration(config.Accrual.Workers.JobTimeout) * time.Second)
+		if visibilityTimeout <= jobTimeout {
+			logger.Alert(fmt.Sprintf(
+				"Accrual queue visibility timeout %s must exceed job timeout %s",
+				visibilityTimeout,
+				jobTimeout,
+			))
+			os.Exit(1)
+		}
+
+		postgresQueue := queue.NewPostgres(
+			connection,
+			visibilityTimeout,
+			time.Duration(config.Accrual.Queue.Postgres.PollInterval)*time.Second,
+			config.Accrual.Queue.MaxRetries,
+			logger,
+		)
+		// Задания ставятся в очередь в одной транзакции с добавлением заказов
+		orderAddHooks = append(orderAddHooks, func(ctx context.Context, tx pgx.Tx, orders []*model.Order) error {
+			bodies := make([][]byte, 0, len(orders))
+			for _, order := range orders {
+				bodies = append(bodies, []byte(order.Number))
+			}
+			return postgresQueue.EnqueueBatch(ctx, tx, bodies)
+		})
+		accrualQueue = postgresQueue
+	case queue.DriverRabbitMQ, "":
+		accrualQueue, err = queue.NewRabbitMQ(
+			config.Accrual.Queue.DSN,
+			"accrual",
+			"accrual",
+			accrual.Prefetch(config.Accrual.Workers.Count, accrualBatching),
+			config.Accrual.Queue.MaxRetries,
+			config.Accrual.Queue.RabbitMQ.OutageBufferSize,
+			logger,
+		)
+		if err != nil {
+			logger.Alert("Failed initialization rabbitmq", err)
+			os.Exit(1)
+		}
+	default:
+		logger.Alert(fmt.Sprintf("Unknown accrual queue driver \"%s\"", config.Accrual.Queue.Driver))
 		os.Exit(1)
 	}
-	defer rabbitmq.Close()
+	defer accrualQueue.Close()
+
+	// Для внешних очередей заказ публикуется через outbox, записанный в транзакции добавления
+	useOutbox := len(orderAddHooks) == 0
+	if useOutbox {
+		orderAddHooks = append(orderAddHooks, pgsql.OutboxOrderAddHook(model.OutboxTopicAccrual))
+	}
 
 	// Repositories
 	userRepository := pgsql.NewUserRepository(connection)
-	orderRepository := pgsql.NewOrderRepository(connection)
+	orderRepository := pgsql.NewOrderRepository(connection, orderAddHooks...)
 	withdrawRepository := pgsql.NewWithdrawRepository(connection)
+	ledgerRepository := pgsql.NewLedgerRepository(connection)
+	idempotencyKeyRepository := pgsql.NewIdempotencyKeyRepository(connection)
+	outboxRepository := pgsql.NewOutboxRepository(connection)
 
 	// Services
 	sAccount := account.New(userRepository, config.App.Secret)
-	sOrder := order.New(orderRepository, rabbitmq)
+	sOrder := order.New(orderRepository)
 	sBalance := balance.New(userRepository, withdrawRepository)
 	sWithdraw := withdraw.New(userRepository, withdrawRepository)
+	sLedger := ledger.New(ledgerRepository, logger)
+	sIdempotency := idempotency.New(
+		idempotencyKeyRepository,
+		time.Duration(config.Idempotency.TTL)*time.Second,
+		time.Duration(config.Idempotency.Lease)*time.Second,
+		logger,
+	)
+	sDeadLetter := deadletter.New(accrualQueue, logger)
+	sHealth := health.New()
+	sMetrics := metrics.New()
+	outboxRelay := outbox.NewRelay(
+		outboxRepository,
+		accrualQueue,
+		time.Duration(config.Outbox.Interval)*time.Second,
+		config.Outbox.BatchSize,
+		time.Duration(config.Outbox.Lease)*time.Second,
+		logger,
+	)
+	if useOutbox {
+		sHealth.Register("outbox", outboxRelay)
+	}
+	if checker, ok := accrualQueue.(health.Checker); ok {
+		sHealth.Register("queue", checker)
+	}
+
+	// Изменения заказов и списаний доставляются через Postgres всем экземплярам приложения
+	eventsHub := events.NewHub(config.Events.HistorySize, config.Events.BufferSize)
+	eventsListener := events.NewListener(connection, pgsql.UserEventsChannel, eventsHub, logger)
+	sHealth.Register("events", eventsListener)
+	sNotifier := notification.New(eventsHub, sBalance, config.WebSocket.BufferSize, logger)
+
+	// Ledger consistency check
+	if _, err = sLedger.Verify(context.Background()); err != nil {
+		logger.Error("Failed ledger consistency check", err)
+	}
 
 	// Initialization accrual system client
+	accrualBreaker := accrual.NewBreaker(
+		accrual.NewHTTPClient(
+			config.Accrual.Service.Address,
+			time.Duration(config.Accrual.Service.Timeout)*time.Second,
+		),
+		config.Accrual.CircuitBreaker.FailureThreshold,
+		time.Duration(config.Accrual.CircuitBreaker.CoolDown)*time.Second,
+	)
+	sHealth.Register("accrual", accrualBreaker)
+	sMetrics.Register(accrualBreaker)
+
 	orderObserver := accrual.NewObserver(
-		resty.New(),
-		config.Accrual.Service.Address,
+		accrualBreaker,
+		accrualQueue,
 		config.Accrual.PoolInterval,
+		accrual.Polling{
+			MaxAttempts: config.Accrual.Polling.MaxAttempts,
+			MaxAge:      time.Duration(config.Accrual.Polling.MaxAge) * time.Second,
+			MaxDelay:    time.Duration(config.Accrual.Polling.MaxDelay) * time.Second,
+		},
+		accrual.NewRateLimiter(config.Accrual.RateLimit.RequestsPerMinute),
+		time.Duration(config.Accrual.RateLimit.DefaultRetryAfter)*time.Second,
 		sOrder,
 		logger,
 	)
 
+	accrualCallback := accrual.NewCallback(
+		orderObserver,
+		sOrder,
+		config.Accrual.Callback.Secret,
+		time.Duration(config.Accrual.Callback.Tolerance)*time.Second,
+		logger,
+	)
+
 	workerManager := accrual.NewWorkerManager(
-		rabbitmq,
+		accrualQueue,
 		orderObserver,
+		config.Accrual.Workers.Count,
+		accrualBatching,
+		time.Duration(config.Accrual.Workers.JobTimeout)*time.Second,
 		logger,
 	)
 
-	workerManager.StartWorker(context.Background())
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Заказ, ожидающий следующего опроса, не должен считаться зависшим
+	reconciliationMaxAge := time.Duration(config.Accrual.Reconciliation.MaxAge) * time.Second
+	if reconciliationMaxAge > 0 && reconciliationMaxAge <= orderObserver.MaxBackoff() {
+		logger.Alert(fmt.Sprintf(
+			"Reconciliation max age %s must exceed accrual polling max delay %s",
+			reconciliationMaxAge,
+			orderObserver.MaxBackoff(),
+		))
+		os.Exit(1)
+	}
+
+	// Recovery of orders stuck in non-final statuses before the worker starts
+	reconciler := reconciliation.New(
+		orderRepository,
+		accrualQueue,
+		reconciliationMaxAge,
+		time.Duration(config.Accrual.Reconciliation.Interval)*time.Second,
+		config.Accrual.Reconciliation.BatchSize,
+		logger,
+	)
+	sHealth.Register("reconciliation", reconciler)
+
+	if config.Accrual.Reconciliation.BatchSize > 0 {
+		if _, err = reconciler.Run(context.Background()); err != nil {
+			logger.Error("Failed orders reconciliation at startup", err)
+		}
+	}
+
+	workerManager.StartWorker(ctx)
 
 	// Starting server and wait signal for graceful shutdown
 	router := srv.NewRouter(
@@ -102,17 +271,52 @@ func main() {
 		sOrder,
 		sBalance,
 		sWithdraw,
+		sIdempotency,
+		sHealth,
+		sMetrics,
+		sDeadLetter,
+		sLedger,
+		accrualCallback,
+		eventsHub,
+		time.Duration(config.Events.HeartbeatInterval)*time.Second,
+		sNotifier,
 		config.App.Secret,
+		config.App.AdminToken,
+		config.Accrual.Callback.Secret,
 		logger,
 	)
 
 	server := srv.New(config.Server.Address, router, logger)
+	server.RegisterOnShutdown(eventsHub.Close)
+	server.OnShutdown(sNotifier.Shutdown)
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+	sIdempotency.StartCleaner(ctx, time.Duration(config.Idempotency.CleanupInterval)*time.Second)
+
+	if useOutbox {
+		outboxRelay.Start(ctx)
+		outboxRelay.StartCleaner(
+			ctx,
+			time.Duration(config.Outbox.CleanupInterval)*time.Second,
+			time.Duration(config.Outbox.Retention)*time.Second,
+		)
+	}
+
+	reconciler.Start(ctx)
+	eventsListener.Start(ctx)
 
 	if err = server.Run(ctx); err != nil {
 		logger.Critical("Failed running server", err)
 		os.Exit(1)
 	}
+
+	// Drain in-flight accrual jobs before the queue is closed
+	drainCtx, cancelDrain := context.WithTimeout(
+		context.Background(),
+		time.Duration(config.Accrual.Workers.DrainTimeout)*time.Second,
+	)
+	defer cancelDrain()
+
+	if err = workerManager.Shutdown(drainCtx); err != nil {
+		logger.Warning("Accrual workers were interrupted before finishing jobs", err)
+	}
 }
@@ -0,0 +1,276 @@
+package accrualmock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+	"github.com/casnerano/yandex-gophermart/pkg/luhn"
+)
+
+// Options поведение симулятора, отличающее его от идеального сервиса.
+type Options struct {
+	// Latency задержка ответа, к которой добавляется случайная часть до Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+	// RequestsPerMinute лимит запросов статуса заказа, при превышении отвечает 429
+	RequestsPerMinute int
+	// FaultRate доля запросов, на которые отвечает FaultStatus
+	FaultRate   float64
+	FaultStatus int
+}
+
+type registerOrderRequest struct {
+	Order  string `json:"order"`
+	Goods  []Good `json:"goods"`
+	Script string `json:"script,omitempty"`
+}
+
+type batchOrdersRequest struct {
+	Orders []string `json:"orders"`
+}
+
+type Server struct {
+	simulator *Simulator
+	options   Options
+	logger    logger.Logger
+
+	mu          sync.Mutex
+	random      *rand.Rand
+	windowStart time.Time
+	windowCount int
+}
+
+func NewServer(simulator *Simulator, options Options, logger logger.Logger) *Server {
+	if options.FaultStatus == 0 {
+		options.FaultStatus = http.StatusInternalServerError
+	}
+
+	return &Server{
+		simulator: simulator,
+		options:   options,
+		logger:    logger,
+		random:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s *Server) Router() *chi.Mux {
+	router := chi.NewRouter()
+
+	router.Use(chiMiddleware.RequestID)
+	router.Use(chiMiddleware.Recoverer)
+	router.Use(s.simulateLatency)
+	router.Use(s.injectFaults)
+
+	router.With(s.throttle).Get("/api/orders/{number}", s.GetOrder())
+	router.With(s.throttle).Post("/api/orders/batch", s.PostOrdersBatch())
+	router.Post("/api/orders", s.PostOrder())
+	router.Post("/api/goods", s.PostGoods())
+
+	return router
+}
+
+func (s *Server) GetOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := s.simulator.GetOrder(chi.URLParam(r, "number"))
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+			s.logger.Error("Failed get order", err)
+			return
+		}
+
+		bResult, err := json.Marshal(result)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			s.logger.Error("Failed marshaller order", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, string(bResult))
+	}
+}
+
+// PostOrdersBatch статусы нескольких заказов одним запросом, который учитывается
+// в лимите как один. Незарегистрированные заказы в ответ не попадают.
+func (s *Server) PostOrdersBatch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request := batchOrdersRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request.Orders) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		results := make([]Result, 0, len(request.Orders))
+		for _, number := range request.Orders {
+			result, err := s.simulator.GetOrder(number)
+			if err != nil {
+				if errors.Is(err, ErrNotFound) {
+					continue
+				}
+
+				w.WriteHeader(http.StatusInternalServerError)
+				s.logger.Error("Failed get order", err)
+				return
+			}
+			results = append(results, result)
+		}
+
+		bResults, err := json.Marshal(results)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			s.logger.Error("Failed marshaller orders", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, string(bResults))
+	}
+}
+
+func (s *Server) PostOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request := registerOrderRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !luhn.Checksum(request.Order) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var script []Step
+		if request.Script != "" {
+			var err error
+			if script, err = ParseScript(request.Script); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, err.Error())
+				return
+			}
+		}
+
+		if err := s.simulator.AddOrder(request.Order, request.Goods, script); err != nil {
+			if errors.Is(err, ErrAlreadyExist) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+			s.logger.Error("Failed register order", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (s *Server) PostGoods() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reward := Reward{}
+		err := json.NewDecoder(r.Body).Decode(&reward)
+		if err != nil || reward.Match == "" || reward.Reward.IsNegative() ||
+			reward.RewardType != RewardTypePercent && reward.RewardType != RewardTypePoints {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err = s.simulator.AddReward(&reward); err != nil {
+			if errors.Is(err, ErrAlreadyExist) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+			s.logger.Error("Failed register reward", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) simulateLatency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delay := s.options.Latency
+		if s.options.Jitter > 0 {
+			s.mu.Lock()
+			delay += time.Duration(s.random.Int63n(int64(s.options.Jitter)))
+			s.mu.Unlock()
+		}
+
+		if delay > 0 {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(delay):
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) injectFaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.options.FaultRate > 0 {
+			s.mu.Lock()
+			fault := s.random.Float64() < s.options.FaultRate
+			s.mu.Unlock()
+
+			if fault {
+				w.WriteHeader(s.options.FaultStatus)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Ограничивает количество запросов в минуту фиксированным окном, как это делает система начислений.
+func (s *Server) throttle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.options.RequestsPerMinute <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		s.mu.Lock()
+		now := time.Now()
+		if now.Sub(s.windowStart) >= time.Minute {
+			s.windowStart, s.windowCount = now, 0
+		}
+		s.windowCount++
+		allowed := s.windowCount <= s.options.RequestsPerMinute
+		retryAfter := s.windowStart.Add(time.Minute).Sub(now)
+		s.mu.Unlock()
+
+		if !allowed {
+			seconds := int(retryAfter.Round(time.Second) / time.Second)
+			if seconds < 1 {
+				seconds = 1
+			}
+
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprintf(w, "No more than %d requests per minute allowed", s.options.RequestsPerMinute)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
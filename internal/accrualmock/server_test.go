@@ -0,0 +1,115 @@
+package accrualmock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
+
+func TestServer(t *testing.T) {
+	script, err := ParseScript("REGISTERED:1m,PROCESSING:1m,PROCESSED")
+	if err != nil {
+		t.Fatalf("ParseScript() error = %v", err)
+	}
+
+	now := time.Now()
+	simulator := NewSimulator(script, 0)
+	simulator.now = func() time.Time { return now }
+
+	server := httptest.NewServer(NewServer(simulator, Options{RequestsPerMinute: 5}, logger.New()).Router())
+	defer server.Close()
+
+	post := func(path, body string) int {
+		response, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s error = %v", path, err)
+		}
+		response.Body.Close()
+		return response.StatusCode
+	}
+
+	get := func(number string) (*http.Response, Result) {
+		response, err := http.Get(server.URL + "/api/orders/" + number)
+		if err != nil {
+			t.Fatalf("GET error = %v", err)
+		}
+		defer response.Body.Close()
+
+		result := Result{}
+		if response.StatusCode == http.StatusOK {
+			if err = json.NewDecoder(response.Body).Decode(&result); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+		}
+		return response, result
+	}
+
+	if code := post("/api/goods", `{"match":"Bork","reward":10,"reward_type":"%"}`); code != http.StatusOK {
+		t.Errorf("POST /api/goods = %d, want %d", code, http.StatusOK)
+	}
+	if code := post("/api/goods", `{"match":"Bork","reward":5,"reward_type":"pt"}`); code != http.StatusConflict {
+		t.Errorf("POST /api/goods duplicate = %d, want %d", code, http.StatusConflict)
+	}
+	if code := post("/api/orders", `{"order":"70482","goods":[{"description":"Чайник Bork","price":7000.55}]}`); code != http.StatusAccepted {
+		t.Errorf("POST /api/orders = %d, want %d", code, http.StatusAccepted)
+	}
+	if code := post("/api/orders", `{"order":"70483","goods":[]}`); code != http.StatusBadRequest {
+		t.Errorf("POST /api/orders invalid number = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	if response, _ := get("18"); response.StatusCode != http.StatusNoContent {
+		t.Errorf("GET unregistered = %d, want %d", response.StatusCode, http.StatusNoContent)
+	}
+
+	if _, result := get("70482"); result.Status != StatusRegistered {
+		t.Errorf("GET status = %s, want %s", result.Status, StatusRegistered)
+	}
+
+	now = now.Add(90 * time.Second)
+	if _, result := get("70482"); result.Status != StatusProcessing {
+		t.Errorf("GET status = %s, want %s", result.Status, StatusProcessing)
+	}
+
+	if code := post("/api/orders/batch", `{"orders":["70482","18"]}`); code != http.StatusOK {
+		t.Errorf("POST /api/orders/batch = %d, want %d", code, http.StatusOK)
+	}
+
+	now = now.Add(time.Minute)
+	_, result := get("70482")
+	if want := (Result{Order: "70482", Status: StatusProcessed, Accrual: money.FromMinor(70006)}); result != want {
+		t.Errorf("GET = %+v, want %+v", result, want)
+	}
+
+	response, _ := get("70482")
+	if response.StatusCode != http.StatusTooManyRequests || response.Header.Get("Retry-After") == "" {
+		t.Errorf("GET over limit = %d, want %d with Retry-After", response.StatusCode, http.StatusTooManyRequests)
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	tests := []struct {
+		script  string
+		wantErr bool
+	}{
+		{"REGISTERED:1s,PROCESSING:2s,PROCESSED", false},
+		{"invalid", false},
+		{"PROCESSED", false},
+		{"PROCESSING:1s", true},
+		{"PROCESSED,PROCESSING:1s,INVALID", true},
+		{"REGISTERED:soon,PROCESSED", true},
+		{"UNKNOWN:1s,PROCESSED", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.script, func(t *testing.T) {
+			if _, err := ParseScript(tt.script); (err != nil) != tt.wantErr {
+				t.Errorf("ParseScript() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
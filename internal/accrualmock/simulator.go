@@ -0,0 +1,223 @@
+// Package accrualmock симулятор системы расчета начислений для локальной разработки
+// и сквозных тестов без внешнего сервиса.
+package accrualmock
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
+
+const (
+	StatusRegistered = "REGISTERED"
+	StatusInvalid    = "INVALID"
+	StatusProcessing = "PROCESSING"
+	StatusProcessed  = "PROCESSED"
+)
+
+const (
+	RewardTypePercent = "%"
+	RewardTypePoints  = "pt"
+)
+
+var (
+	ErrAlreadyExist = errors.New("already exist")
+	ErrNotFound     = errors.New("not found")
+	ErrInvalidStep  = errors.New("invalid script step")
+)
+
+// Step шаг сценария: статус заказа и время, в течение которого он сохраняется.
+// Последний шаг сценария должен содержать финальный статус.
+type Step struct {
+	Status   string
+	Duration time.Duration
+}
+
+// ParseScript разбирает сценарий вида "REGISTERED:1s,PROCESSING:2s,PROCESSED".
+func ParseScript(value string) ([]Step, error) {
+	parts := strings.Split(value, ",")
+	script := make([]Step, 0, len(parts))
+	for i, part := range parts {
+		status, rawDuration, _ := strings.Cut(strings.TrimSpace(part), ":")
+
+		step := Step{Status: strings.ToUpper(status)}
+		if rawDuration != "" {
+			duration, err := time.ParseDuration(rawDuration)
+			if err != nil {
+				return nil, fmt.Errorf("%w \"%s\": %v", ErrInvalidStep, part, err)
+			}
+			step.Duration = duration
+		}
+
+		final := step.Status == StatusProcessed || step.Status == StatusInvalid
+		if step.Status != StatusRegistered && step.Status != StatusProcessing && !final {
+			return nil, fmt.Errorf("%w \"%s\": unknown status", ErrInvalidStep, part)
+		}
+		if final != (i == len(parts)-1) {
+			return nil, fmt.Errorf("%w \"%s\": only the last step must be final", ErrInvalidStep, part)
+		}
+
+		script = append(script, step)
+	}
+
+	return script, nil
+}
+
+// Good товар в составе заказа.
+type Good struct {
+	Description string      `json:"description"`
+	Price       money.Money `json:"price"`
+}
+
+// Reward механика вознаграждения за товары, описание которых содержит Match.
+type Reward struct {
+	Match      string      `json:"match"`
+	Reward     money.Money `json:"reward"`
+	RewardType string      `json:"reward_type"`
+}
+
+// Result состояние расчета начисления по заказу.
+type Result struct {
+	Order   string      `json:"order"`
+	Status  string      `json:"status"`
+	Accrual money.Money `json:"accrual,omitempty"`
+}
+
+type order struct {
+	number       string
+	accrual      money.Money
+	script       []Step
+	registeredAt time.Time
+}
+
+// Simulator хранилище заказов и механик вознаграждения в памяти процесса.
+type Simulator struct {
+	mu      sync.RWMutex
+	orders  map[string]*order
+	rewards []*Reward
+	script  []Step
+	// Незарегистрированные заказы регистрируются при первом запросе с начислением autoAccrual
+	autoAccrual money.Money
+	now         func() time.Time
+}
+
+func NewSimulator(script []Step, autoAccrual money.Money) *Simulator {
+	return &Simulator{
+		orders:      make(map[string]*order),
+		script:      script,
+		autoAccrual: autoAccrual,
+		now:         time.Now,
+	}
+}
+
+func (s *Simulator) AddReward(reward *Reward) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.rewards {
+		if existing.Match == reward.Match {
+			return ErrAlreadyExist
+		}
+	}
+	s.rewards = append(s.rewards, reward)
+	return nil
+}
+
+// AddOrder регистрирует заказ и рассчитывает начисление по механикам вознаграждения.
+// Пустой script означает сценарий по умолчанию.
+func (s *Simulator) AddOrder(number string, goods []Good, script []Step) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[number]; ok {
+		return ErrAlreadyExist
+	}
+
+	var accrual money.Money
+	for _, good := range goods {
+		accrual += s.reward(good)
+	}
+
+	s.register(number, accrual, script)
+	return nil
+}
+
+// GetOrder текущий статус заказа согласно его сценарию.
+func (s *Simulator) GetOrder(number string) (Result, error) {
+	s.mu.RLock()
+	found, ok := s.orders[number]
+	s.mu.RUnlock()
+
+	if !ok {
+		if s.autoAccrual.IsZero() {
+			return Result{}, ErrNotFound
+		}
+
+		s.mu.Lock()
+		if found, ok = s.orders[number]; !ok {
+			found = s.register(number, s.autoAccrual, nil)
+		}
+		s.mu.Unlock()
+	}
+
+	elapsed := s.now().Sub(found.registeredAt)
+	step := found.script[len(found.script)-1]
+	for _, candidate := range found.script {
+		if elapsed < candidate.Duration {
+			step = candidate
+			break
+		}
+		elapsed -= candidate.Duration
+	}
+
+	result := Result{Order: found.number, Status: step.Status}
+	if step.Status == StatusProcessed {
+		result.Accrual = found.accrual
+	}
+	return result, nil
+}
+
+// Регистрирует заказ, вызывается под блокировкой mu.
+func (s *Simulator) register(number string, accrual money.Money, script []Step) *order {
+	if len(script) == 0 {
+		script = s.script
+	}
+
+	registered := &order{
+		number:       number,
+		accrual:      accrual,
+		script:       script,
+		registeredAt: s.now(),
+	}
+	s.orders[number] = registered
+	return registered
+}
+
+// Вознаграждение за товар по первой подходящей механике, вызывается под блокировкой mu.
+func (s *Simulator) reward(good Good) money.Money {
+	for _, reward := range s.rewards {
+		if !strings.Contains(good.Description, reward.Match) {
+			continue
+		}
+
+		if reward.RewardType == RewardTypePoints {
+			return reward.Reward
+		}
+		return percent(good.Price, reward.Reward)
+	}
+	return 0
+}
+
+// Процент от суммы с округлением половины от нуля.
+func percent(amount, rate money.Money) money.Money {
+	product := amount.Minor() * rate.Minor()
+	divisor := int64(100 * money.Scale)
+	if product >= 0 {
+		return money.FromMinor((product + divisor/2) / divisor)
+	}
+	return money.FromMinor((product - divisor/2) / divisor)
+}
@@ -49,8 +49,9 @@ func (ae *AppEnv) UnmarshalYAML(value *yaml.Node) error {
 
 type Configuration struct {
 	App struct {
-		ENV    AppEnv `yaml:"env" env:"APP_ENV"`
-		Secret string `yaml:"secret" env:"APP_SECRET"`
+		ENV        AppEnv `yaml:"env" env:"APP_ENV"`
+		Secret     string `yaml:"secret" env:"APP_SECRET"`
+		AdminToken string `yaml:"admin_token" env:"APP_ADMIN_TOKEN"`
 	} `yaml:"app"`
 	Server struct {
 		Address string `yaml:"address" env:"RUN_ADDRESS"`
@@ -61,12 +62,74 @@ type Configuration struct {
 	Accrual struct {
 		Service struct {
 			Address string `yaml:"address" env:"ACCRUAL_SYSTEM_ADDRESS"`
+			Timeout int    `yaml:"timeout"`
 		} `yaml:"service"`
 		Queue struct {
-			DSN string `yaml:"dsn"`
+			Driver     string `yaml:"driver" env:"ACCRUAL_QUEUE_DRIVER"`
+			DSN        string `yaml:"dsn"`
+			BufferSize int    `yaml:"buffer_size"`
+			MaxRetries int    `yaml:"max_retries"`
+			Postgres   struct {
+				VisibilityTimeout int `yaml:"visibility_timeout"`
+				PollInterval      int `yaml:"poll_interval"`
+			} `yaml:"postgres"`
+			RabbitMQ struct {
+				OutageBufferSize int `yaml:"outage_buffer_size"`
+			} `yaml:"rabbitmq"`
 		} `yaml:"queue"`
 		PoolInterval int `yaml:"pool_interval"`
+		Polling      struct {
+			MaxAttempts int `yaml:"max_attempts"`
+			MaxAge      int `yaml:"max_age"`
+			MaxDelay    int `yaml:"max_delay"`
+		} `yaml:"polling"`
+		RateLimit struct {
+			RequestsPerMinute int `yaml:"requests_per_minute" env:"ACCRUAL_REQUESTS_PER_MINUTE"`
+			DefaultRetryAfter int `yaml:"default_retry_after"`
+		} `yaml:"rate_limit"`
+		Callback struct {
+			Secret    string `yaml:"secret" env:"ACCRUAL_CALLBACK_SECRET"`
+			Tolerance int    `yaml:"tolerance"`
+		} `yaml:"callback"`
+		CircuitBreaker struct {
+			FailureThreshold int `yaml:"failure_threshold"`
+			CoolDown         int `yaml:"cool_down"`
+		} `yaml:"circuit_breaker"`
+		Workers struct {
+			Count        int `yaml:"count"`
+			JobTimeout   int `yaml:"job_timeout"`
+			DrainTimeout int `yaml:"drain_timeout"`
+		} `yaml:"workers"`
+		Batch struct {
+			Size     int `yaml:"size"`
+			WindowMS int `yaml:"window_ms"`
+		} `yaml:"batch"`
+		Reconciliation struct {
+			MaxAge    int `yaml:"max_age"`
+			Interval  int `yaml:"interval"`
+			BatchSize int `yaml:"batch_size"`
+		} `yaml:"reconciliation"`
 	} `yaml:"accrual"`
+	Outbox struct {
+		Interval        int `yaml:"interval"`
+		BatchSize       int `yaml:"batch_size"`
+		Lease           int `yaml:"lease"`
+		Retention       int `yaml:"retention"`
+		CleanupInterval int `yaml:"cleanup_interval"`
+	} `yaml:"outbox"`
+	Events struct {
+		HistorySize       int `yaml:"history_size"`
+		BufferSize        int `yaml:"buffer_size"`
+		HeartbeatInterval int `yaml:"heartbeat_interval"`
+	} `yaml:"events"`
+	WebSocket struct {
+		BufferSize int `yaml:"buffer_size"`
+	} `yaml:"websocket"`
+	Idempotency struct {
+		TTL             int `yaml:"ttl"`
+		Lease           int `yaml:"lease"`
+		CleanupInterval int `yaml:"cleanup_interval"`
+	} `yaml:"idempotency"`
 }
 
 func New() (*Configuration, error) {
@@ -111,6 +174,7 @@ func (c *Configuration) loadFromFlags() error {
 	flag.StringVar(&c.Server.Address, "a", c.Server.Address, "Server address")
 	flag.StringVar(&c.Database.DSN, "d", c.Database.DSN, "Database data source name")
 	flag.StringVar(&c.Accrual.Service.Address, "r", c.Accrual.Service.Address, "Accrual service address")
+	flag.StringVar(&c.Accrual.Queue.Driver, "qd", c.Accrual.Queue.Driver, "Accrual queue driver (rabbitmq, memory, postgres)")
 	flag.StringVar(&c.Accrual.Queue.DSN, "q", c.Accrual.Queue.DSN, "Accrual queue data source name")
 
 	flag.Parse()
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+type IdempotencyKey struct {
+	Key          string
+	UserUUID     string
+	Fingerprint  string
+	StatusCode   int
+	ResponseBody []byte
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+// Completed признак сохраненного ответа на запрос с этим ключом.
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
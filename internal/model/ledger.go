@@ -0,0 +1,58 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
+
+type LedgerEntryKind string
+
+const (
+	LedgerEntryAccrual    LedgerEntryKind = "ACCRUAL"
+	LedgerEntryWithdrawal LedgerEntryKind = "WITHDRAWAL"
+	LedgerEntryAdjustment LedgerEntryKind = "ADJUSTMENT"
+	LedgerEntryReversal   LedgerEntryKind = "REVERSAL"
+)
+
+// LedgerAccount счет журнала. Каждая проводка изменяет счет пользователя
+// и противоположный ему системный счет на одну и ту же сумму с разным знаком.
+type LedgerAccount string
+
+const (
+	LedgerAccountUser        LedgerAccount = "USER"
+	LedgerAccountAccrual     LedgerAccount = "ACCRUAL"
+	LedgerAccountWithdrawals LedgerAccount = "WITHDRAWALS"
+	LedgerAccountAdjustments LedgerAccount = "ADJUSTMENTS"
+)
+
+// LedgerEntry проводка с точки зрения счета пользователя:
+// положительная сумма увеличивает баланс, отрицательная уменьшает.
+type LedgerEntry struct {
+	UUID       string          `json:"uuid"`
+	Kind       LedgerEntryKind `json:"kind"`
+	Amount     money.Money     `json:"amount"`
+	UserUUID   string          `json:"-"`
+	Reference  string          `json:"reference,omitempty"`
+	ReversalOf string          `json:"reversal_of,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+func (e LedgerEntry) MarshalJSON() ([]byte, error) {
+	type LedgerEntryAlias LedgerEntry
+	return json.Marshal(&struct {
+		LedgerEntryAlias
+		CreatedAt string `json:"created_at"`
+	}{
+		LedgerEntryAlias: LedgerEntryAlias(e),
+		CreatedAt:        e.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+// LedgerDiscrepancy расхождение кэшированного баланса пользователя с суммой его проводок.
+type LedgerDiscrepancy struct {
+	UserUUID      string      `json:"user_uuid"`
+	Balance       money.Money `json:"balance"`
+	LedgerBalance money.Money `json:"ledger_balance"`
+}
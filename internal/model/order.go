@@ -3,6 +3,8 @@ package model
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/casnerano/yandex-gophermart/pkg/money"
 )
 
 type OrderStatus string
@@ -14,11 +16,30 @@ const (
 	OrderStatusProcessed  OrderStatus = "PROCESSED"
 )
 
+// IsFinal финальные статусы больше не изменяются.
+func (s OrderStatus) IsFinal() bool {
+	return s == OrderStatusProcessed || s == OrderStatusInvalid
+}
+
+// CanTransitionTo допустим ли переход заказа в статус next. Заказ в не финальном статусе
+// может оставаться в нем, но не может вернуться из PROCESSING в NEW.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	if s.IsFinal() {
+		return false
+	}
+
+	if next == OrderStatusNew {
+		return s == OrderStatusNew
+	}
+
+	return next == OrderStatusProcessing || next.IsFinal()
+}
+
 type Order struct {
 	UUID       string      `json:"-"`
 	Number     string      `json:"number"`
 	Status     OrderStatus `json:"status"`
-	Accrual    float64     `json:"accrual,omitempty"`
+	Accrual    money.Money `json:"accrual,omitempty"`
 	UserUUID   string      `json:"-"`
 	UploadedAt time.Time   `json:"uploaded_at"`
 }
@@ -33,3 +54,32 @@ func (o Order) MarshalJSON() ([]byte, error) {
 		UploadedAt: o.UploadedAt.Format(time.RFC3339),
 	})
 }
+
+// OrderStatusSource источник изменения статуса заказа.
+type OrderStatusSource string
+
+const (
+	OrderStatusSourcePolling  OrderStatusSource = "POLLING"
+	OrderStatusSourceCallback OrderStatusSource = "CALLBACK"
+)
+
+// OrderStatusChange запись истории статусов заказа. ResponseCode равен нулю,
+// если изменение получено не ответом системы начислений.
+type OrderStatusChange struct {
+	Status       OrderStatus       `json:"status"`
+	Accrual      money.Money       `json:"accrual,omitempty"`
+	Source       OrderStatusSource `json:"source"`
+	ResponseCode int               `json:"response_code,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+func (c OrderStatusChange) MarshalJSON() ([]byte, error) {
+	type OrderStatusChangeAlias OrderStatusChange
+	return json.Marshal(&struct {
+		OrderStatusChangeAlias
+		CreatedAt string `json:"created_at"`
+	}{
+		OrderStatusChangeAlias: OrderStatusChangeAlias(c),
+		CreatedAt:              c.CreatedAt.Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,29 @@
+package model
+
+import "testing"
+
+func TestOrderStatus_CanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from OrderStatus
+		to   OrderStatus
+		want bool
+	}{
+		{OrderStatusNew, OrderStatusProcessing, true},
+		{OrderStatusNew, OrderStatusProcessed, true},
+		{OrderStatusNew, OrderStatusInvalid, true},
+		{OrderStatusProcessing, OrderStatusProcessing, true},
+		{OrderStatusProcessing, OrderStatusProcessed, true},
+		{OrderStatusProcessing, OrderStatusInvalid, true},
+		{OrderStatusProcessing, OrderStatusNew, false},
+		{OrderStatusProcessed, OrderStatusProcessed, false},
+		{OrderStatusProcessed, OrderStatusInvalid, false},
+		{OrderStatusInvalid, OrderStatusProcessed, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+				t.Errorf("CanTransitionTo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+const OutboxTopicAccrual = "accrual"
+
+type OutboxMessage struct {
+	UUID      string
+	Topic     string
+	Payload   []byte
+	Attempts  int
+	CreatedAt time.Time
+}
@@ -1,11 +1,15 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
 
 type User struct {
-	UUID      string    `json:"uuid"`
-	Login     string    `json:"login"`
-	Balance   float64   `json:"balance"`
-	Password  string    `json:"-"`
-	CreatedAt time.Time `json:"created_at"`
+	UUID      string      `json:"uuid"`
+	Login     string      `json:"login"`
+	Balance   money.Money `json:"balance"`
+	Password  string      `json:"-"`
+	CreatedAt time.Time   `json:"created_at"`
 }
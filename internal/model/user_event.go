@@ -0,0 +1,19 @@
+package model
+
+import "encoding/json"
+
+type UserEventType string
+
+const (
+	UserEventOrder      UserEventType = "order"
+	UserEventWithdrawal UserEventType = "withdrawal"
+)
+
+// UserEvent изменение данных пользователя для доставки клиентам в реальном времени.
+// Номера событий возрастают и общие для всех экземпляров приложения.
+type UserEvent struct {
+	ID       int64           `json:"id"`
+	Type     UserEventType   `json:"type"`
+	UserUUID string          `json:"user_uuid"`
+	Data     json.RawMessage `json:"data"`
+}
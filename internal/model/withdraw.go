@@ -3,14 +3,16 @@ package model
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/casnerano/yandex-gophermart/pkg/money"
 )
 
 type Withdraw struct {
-	UUID        string    `json:"-"`
-	OrderNumber string    `json:"order"`
-	Amount      float64   `json:"sum"`
-	UserUUID    string    `json:"-"`
-	ProcessedAt time.Time `json:"processed_at"`
+	UUID        string      `json:"-"`
+	OrderNumber string      `json:"order"`
+	Amount      money.Money `json:"sum"`
+	UserUUID    string      `json:"-"`
+	ProcessedAt time.Time   `json:"processed_at"`
 }
 
 func (w Withdraw) MarshalJSON() ([]byte, error) {
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+)
+
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// Cursor позиция в списке, упорядоченном по времени и идентификатору записи.
+type Cursor struct {
+	Time time.Time
+	UUID string
+}
+
+// Encode непрозрачное представление курсора для передачи клиенту.
+func (c Cursor) Encode() string {
+	raw := strconv.FormatInt(c.Time.UnixMicro(), 10) + "." + c.UUID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(value string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	rawTime, uuid, found := strings.Cut(string(raw), ".")
+	if !found || !isUUID(uuid) {
+		return nil, ErrInvalidCursor
+	}
+
+	micro, err := strconv.ParseInt(rawTime, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{Time: time.UnixMicro(micro).UTC(), UUID: uuid}, nil
+}
+
+// Page параметры выборки страницы списка. Страница начинается после Cursor,
+// период ограничивается From включительно и To исключительно, нулевые границы не применяются.
+type Page struct {
+	Limit  int
+	Cursor *Cursor
+	Desc   bool
+	From   time.Time
+	To     time.Time
+}
+
+// OrderQuery выборка страницы заказов, пустой Statuses не ограничивает статус.
+type OrderQuery struct {
+	Page
+	Statuses []model.OrderStatus
+}
+
+func isUUID(value string) bool {
+	if len(value) != 36 {
+		return false
+	}
+
+	for i, char := range value {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if char != '-' {
+				return false
+			}
+		case !strings.ContainsRune("0123456789abcdefABCDEF", char):
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	cursor := Cursor{Time: time.Date(2023, 4, 1, 12, 30, 15, 123456000, time.UTC), UUID: "6f1c2a3e-8a7b-4c1d-9e2f-0a1b2c3d4e5f"}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor() error = %v", err)
+	}
+	if !decoded.Time.Equal(cursor.Time) || decoded.UUID != cursor.UUID {
+		t.Errorf("DecodeCursor() = %+v, want %+v", decoded, cursor)
+	}
+
+	for _, value := range []string{
+		"",
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("1680352215123456")),
+		base64.RawURLEncoding.EncodeToString([]byte("soon.6f1c2a3e-8a7b-4c1d-9e2f-0a1b2c3d4e5f")),
+		base64.RawURLEncoding.EncodeToString([]byte("1680352215123456.6f1c2a3e'; drop table orders; --")),
+	} {
+		if _, err = DecodeCursor(value); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeCursor(%q) error = %v, want %v", value, err, ErrInvalidCursor)
+		}
+	}
+}
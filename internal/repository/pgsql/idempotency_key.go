@@ -0,0 +1,106 @@
+package pgsql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+)
+
+type IdempotencyKeyRepository struct {
+	pgxpool *pgxpool.Pool
+}
+
+func NewIdempotencyKeyRepository(pgxpool *pgxpool.Pool) repository.IdempotencyKey {
+	return &IdempotencyKeyRepository{pgxpool}
+}
+
+func (i *IdempotencyKeyRepository) Acquire(ctx context.Context, userUUID, key, fingerprint string, lease time.Duration) (*model.IdempotencyKey, error) {
+	idempotencyKey := model.IdempotencyKey{Key: key, UserUUID: userUUID, Fingerprint: fingerprint}
+	err := i.pgxpool.QueryRow(
+		ctx,
+		`insert into idempotency_keys(key, user_uuid, fingerprint, expires_at) values($1, $2, $3, now() + $4::interval)
+		on conflict (user_uuid, key) do update
+		set fingerprint = excluded.fingerprint, status_code = null, response_body = null, created_at = now(), expires_at = excluded.expires_at
+		where idempotency_keys.expires_at < now()
+		returning created_at, expires_at`,
+		key,
+		userUUID,
+		fingerprint,
+		lease,
+	).Scan(
+		&idempotencyKey.CreatedAt,
+		&idempotencyKey.ExpiresAt,
+	)
+
+	if err == nil {
+		return &idempotencyKey, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	var statusCode *int
+	err = i.pgxpool.QueryRow(
+		ctx,
+		"select fingerprint, status_code, response_body, created_at, expires_at from idempotency_keys where user_uuid = $1 and key = $2",
+		userUUID,
+		key,
+	).Scan(
+		&idempotencyKey.Fingerprint,
+		&statusCode,
+		&idempotencyKey.ResponseBody,
+		&idempotencyKey.CreatedAt,
+		&idempotencyKey.ExpiresAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = repository.ErrNotFound
+		}
+		return nil, err
+	}
+
+	if statusCode != nil {
+		idempotencyKey.StatusCode = *statusCode
+	}
+
+	return &idempotencyKey, repository.ErrAlreadyExist
+}
+
+func (i *IdempotencyKeyRepository) Complete(ctx context.Context, userUUID, key string, statusCode int, responseBody []byte, ttl time.Duration) error {
+	_, err := i.pgxpool.Exec(
+		ctx,
+		"update idempotency_keys set status_code = $1, response_body = $2, expires_at = now() + $3::interval where user_uuid = $4 and key = $5",
+		statusCode,
+		responseBody,
+		ttl,
+		userUUID,
+		key,
+	)
+	return err
+}
+
+func (i *IdempotencyKeyRepository) Release(ctx context.Context, userUUID, key string) error {
+	_, err := i.pgxpool.Exec(
+		ctx,
+		"delete from idempotency_keys where user_uuid = $1 and key = $2 and status_code is null",
+		userUUID,
+		key,
+	)
+	return err
+}
+
+func (i *IdempotencyKeyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := i.pgxpool.Exec(ctx, "delete from idempotency_keys where expires_at < now()")
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,306 @@
+package pgsql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
+
+type LedgerRepository struct {
+	pgxpool *pgxpool.Pool
+}
+
+func NewLedgerRepository(pgxpool *pgxpool.Pool) repository.Ledger {
+	return &LedgerRepository{pgxpool}
+}
+
+func (l *LedgerRepository) Adjust(ctx context.Context, userUUID string, amount money.Money, reason string) (*model.LedgerEntry, error) {
+	entry := model.LedgerEntry{
+		Kind:      model.LedgerEntryAdjustment,
+		Amount:    amount,
+		UserUUID:  userUUID,
+		Reference: reason,
+	}
+
+	tx, err := l.pgxpool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = lockBalanceChange(ctx, tx, userUUID, amount); err != nil {
+		return nil, ledgerError(err)
+	}
+
+	err = postLedgerEntry(ctx, tx, &entry, model.LedgerAccountAdjustments)
+	if err != nil {
+		return nil, ledgerError(err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (l *LedgerRepository) Reverse(ctx context.Context, entryUUID string, reason string) (*model.LedgerEntry, error) {
+	tx, err := l.pgxpool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		original model.LedgerEntry
+		counter  model.LedgerAccount
+	)
+	err = tx.QueryRow(
+		ctx,
+		`select u.amount, u.user_uuid, c.account
+		from ledger u
+		join ledger c on c.entry_uuid = u.entry_uuid and c.account <> 'USER'
+		where u.entry_uuid = $1 and u.account = 'USER'`,
+		entryUUID,
+	).Scan(
+		&original.Amount,
+		&original.UserUUID,
+		&counter,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = repository.ErrNotFound
+		}
+		return nil, ledgerError(err)
+	}
+
+	entry := model.LedgerEntry{
+		Kind:       model.LedgerEntryReversal,
+		Amount:     -original.Amount,
+		UserUUID:   original.UserUUID,
+		Reference:  reason,
+		ReversalOf: entryUUID,
+	}
+
+	// Сторно начисления, баллы которого уже списаны, не должно уводить баланс в минус
+	if err = lockBalanceChange(ctx, tx, entry.UserUUID, entry.Amount); err != nil {
+		return nil, ledgerError(err)
+	}
+
+	err = postLedgerEntry(ctx, tx, &entry, counter)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			err = repository.ErrLedgerEntryAlreadyReversed
+		}
+		return nil, ledgerError(err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (l *LedgerRepository) FindByUUID(ctx context.Context, uuid string) (*model.LedgerEntry, error) {
+	entry := model.LedgerEntry{UUID: uuid}
+	err := l.pgxpool.QueryRow(
+		ctx,
+		`select kind, amount, user_uuid, coalesce(reference, ''), coalesce(reversal_of::text, ''), created_at
+		from ledger where entry_uuid = $1 and account = 'USER'`,
+		uuid,
+	).Scan(
+		&entry.Kind,
+		&entry.Amount,
+		&entry.UserUUID,
+		&entry.Reference,
+		&entry.ReversalOf,
+		&entry.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = repository.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (l *LedgerRepository) FindAllByUserUUID(ctx context.Context, userUUID string) ([]*model.LedgerEntry, error) {
+	entries := make([]*model.LedgerEntry, 0)
+	rows, err := l.pgxpool.Query(
+		ctx,
+		`select entry_uuid, kind, amount, user_uuid, coalesce(reference, ''), coalesce(reversal_of::text, ''), created_at
+		from ledger where user_uuid = $1 and account = 'USER' order by created_at`,
+		userUUID,
+	)
+
+	if err != nil {
+		return nil, ledgerError(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		entry := &model.LedgerEntry{}
+		err = rows.Scan(
+			&entry.UUID,
+			&entry.Kind,
+			&entry.Amount,
+			&entry.UserUUID,
+			&entry.Reference,
+			&entry.ReversalOf,
+			&entry.CreatedAt,
+		)
+		if err == nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ledgerError(err)
+	}
+
+	return entries, nil
+}
+
+func (l *LedgerRepository) FindDiscrepancies(ctx context.Context) ([]*model.LedgerDiscrepancy, error) {
+	discrepancies := make([]*model.LedgerDiscrepancy, 0)
+	rows, err := l.pgxpool.Query(
+		ctx,
+		`select u.uuid, coalesce(u.balance, 0), coalesce(sum(l.amount), 0)
+		from users u
+		left join ledger l on l.user_uuid = u.uuid and l.account = 'USER'
+		group by u.uuid, u.balance
+		having coalesce(u.balance, 0) <> coalesce(sum(l.amount), 0)`,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		discrepancy := &model.LedgerDiscrepancy{}
+		err = rows.Scan(
+			&discrepancy.UserUUID,
+			&discrepancy.Balance,
+			&discrepancy.LedgerBalance,
+		)
+		if err != nil {
+			return nil, err
+		}
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	return discrepancies, rows.Err()
+}
+
+// Блокирует строку пользователя до конца транзакции и проверяет, что изменение баланса
+// на amount не сделает его отрицательным. Возвращает ErrNotFound для неизвестного пользователя.
+func lockBalanceChange(ctx context.Context, tx pgx.Tx, userUUID string, amount money.Money) error {
+	var balance money.Money
+	err := tx.QueryRow(
+		ctx,
+		"select coalesce(balance, 0) from users where uuid = $1 for update",
+		userUUID,
+	).Scan(&balance)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = repository.ErrNotFound
+		}
+		return err
+	}
+
+	if balance+amount < 0 {
+		return repository.ErrLedgerNegativeBalance
+	}
+
+	return nil
+}
+
+// Приводит ошибки Postgres к ошибкам репозитория: идентификатор не в формате UUID
+// не может принадлежать существующей записи, а нарушение ограничения баланса
+// означает попытку увести его в минус.
+func ledgerError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case pgerrcode.InvalidTextRepresentation, pgerrcode.ForeignKeyViolation:
+		return repository.ErrNotFound
+	case pgerrcode.CheckViolation:
+		return repository.ErrLedgerNegativeBalance
+	}
+	return err
+}
+
+// Записывает проводку на счет пользователя и противоположную ей на счет counter,
+// после чего обновляет кэшированный баланс пользователя. Вызывается внутри транзакции
+// вместе с изменением сущности, породившей проводку.
+func postLedgerEntry(ctx context.Context, tx pgx.Tx, entry *model.LedgerEntry, counter model.LedgerAccount) error {
+	err := tx.QueryRow(
+		ctx,
+		`insert into ledger(entry_uuid, account, kind, amount, user_uuid, reference, reversal_of)
+		values(uuid_generate_v4(), $1, $2, $3, $4, nullif($5, ''), nullif($6, '')::uuid)
+		returning entry_uuid, created_at`,
+		model.LedgerAccountUser,
+		entry.Kind,
+		entry.Amount,
+		entry.UserUUID,
+		entry.Reference,
+		entry.ReversalOf,
+	).Scan(
+		&entry.UUID,
+		&entry.CreatedAt,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`insert into ledger(entry_uuid, account, kind, amount, user_uuid, reference, reversal_of, created_at)
+		values($1, $2, $3, $4, $5, nullif($6, ''), nullif($7, '')::uuid, $8)`,
+		entry.UUID,
+		counter,
+		entry.Kind,
+		-entry.Amount,
+		entry.UserUUID,
+		entry.Reference,
+		entry.ReversalOf,
+		entry.CreatedAt,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		"update users set balance = balance + $1 where uuid = $2",
+		entry.Amount,
+		entry.UserUUID,
+	)
+
+	return err
+}
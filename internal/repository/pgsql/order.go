@@ -3,6 +3,8 @@ package pgsql
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
@@ -14,12 +16,17 @@ import (
 	"github.com/casnerano/yandex-gophermart/pkg/luhn"
 )
 
+// OrderAddHook выполняется в транзакции добавления заказов,
+// ошибка хука отменяет добавление.
+type OrderAddHook func(ctx context.Context, tx pgx.Tx, orders []*model.Order) error
+
 type OrderRepository struct {
-	pgxpool *pgxpool.Pool
+	pgxpool  *pgxpool.Pool
+	addHooks []OrderAddHook
 }
 
-func NewOrderRepository(pgxpool *pgxpool.Pool) repository.Order {
-	return &OrderRepository{pgxpool}
+func NewOrderRepository(pgxpool *pgxpool.Pool, addHooks ...OrderAddHook) repository.Order {
+	return &OrderRepository{pgxpool: pgxpool, addHooks: addHooks}
 }
 
 func (p *OrderRepository) Add(ctx context.Context, number, userUUID string) (*model.Order, error) {
@@ -27,8 +34,15 @@ func (p *OrderRepository) Add(ctx context.Context, number, userUUID string) (*mo
 		return nil, repository.ErrOrderIncorrectNumber
 	}
 
-	order := model.Order{Number: number, UserUUID: userUUID}
-	err := p.pgxpool.QueryRow(
+	order := model.Order{Number: number, Status: model.OrderStatusNew, UserUUID: userUUID}
+
+	tx, err := p.pgxpool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(
 		ctx,
 		"insert into orders(number, status, user_uuid) values($1, $2, $3) returning uuid, accrual, uploaded_at",
 		number,
@@ -48,9 +62,121 @@ func (p *OrderRepository) Add(ctx context.Context, number, userUUID string) (*mo
 		return nil, err
 	}
 
+	for _, hook := range p.addHooks {
+		if err = hook(ctx, tx, []*model.Order{&order}); err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	return &order, nil
 }
 
+func (p *OrderRepository) AddBatch(ctx context.Context, numbers []string, userUUID string) ([]*model.Order, []*model.Order, error) {
+	for _, number := range numbers {
+		if !luhn.Checksum(number) {
+			return nil, nil, repository.ErrOrderIncorrectNumber
+		}
+	}
+
+	tx, err := p.pgxpool.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(
+		ctx,
+		`insert into orders(number, status, user_uuid)
+		select unnest($1::text[]), $2, $3
+		on conflict (number) do nothing
+		returning uuid, number, accrual, uploaded_at`,
+		numbers,
+		model.OrderStatusNew,
+		userUUID,
+	)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	added := make([]*model.Order, 0, len(numbers))
+	for rows.Next() {
+		order := &model.Order{Status: model.OrderStatusNew, UserUUID: userUUID}
+		if err = rows.Scan(&order.UUID, &order.Number, &order.Accrual, &order.UploadedAt); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		added = append(added, order)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	existing := make([]*model.Order, 0, len(numbers)-len(added))
+	if len(added) < len(numbers) {
+		addedNumbers := make([]string, 0, len(added))
+		for _, order := range added {
+			addedNumbers = append(addedNumbers, order.Number)
+		}
+
+		rows, err = tx.Query(
+			ctx,
+			`select uuid, number, status, accrual, user_uuid, uploaded_at from orders
+			where number = any($1::text[]) and not number = any($2::text[])`,
+			numbers,
+			addedNumbers,
+		)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for rows.Next() {
+			order := &model.Order{}
+			err = rows.Scan(
+				&order.UUID,
+				&order.Number,
+				&order.Status,
+				&order.Accrual,
+				&order.UserUUID,
+				&order.UploadedAt,
+			)
+			if err != nil {
+				rows.Close()
+				return nil, nil, err
+			}
+			existing = append(existing, order)
+		}
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(added) > 0 {
+		for _, hook := range p.addHooks {
+			if err = hook(ctx, tx, added); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return added, existing, nil
+}
+
 func (p *OrderRepository) FindByNumber(ctx context.Context, number string) (*model.Order, error) {
 	order := model.Order{Number: number}
 	err := p.pgxpool.QueryRow(
@@ -107,7 +233,68 @@ func (p *OrderRepository) FindAllByUserUUID(ctx context.Context, userUUID string
 	return orders, nil
 }
 
-func (p *OrderRepository) AccrueByNumber(ctx context.Context, number string, status model.OrderStatus, accrual float64) (*model.Order, error) {
+func (p *OrderRepository) FindPageByUserUUID(
+	ctx context.Context,
+	userUUID string,
+	query repository.OrderQuery,
+) ([]*model.Order, *repository.Cursor, error) {
+	sql := "select uuid, number, status, accrual, user_uuid, uploaded_at from orders where user_uuid = $1"
+	args := []any{userUUID}
+
+	if len(query.Statuses) > 0 {
+		statuses := make([]string, 0, len(query.Statuses))
+		for _, status := range query.Statuses {
+			statuses = append(statuses, string(status))
+		}
+		args = append(args, statuses)
+		sql += fmt.Sprintf(" and status::text = any($%d::text[])", len(args))
+	}
+
+	sql, args, limit := keysetQuery(sql, args, query.Page, "uploaded_at")
+
+	orders := make([]*model.Order, 0, limit)
+	rows, err := p.pgxpool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		order := &model.Order{}
+		err = rows.Scan(
+			&order.UUID,
+			&order.Number,
+			&order.Status,
+			&order.Accrual,
+			&order.UserUUID,
+			&order.UploadedAt,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(orders) <= limit {
+		return orders, nil, nil
+	}
+
+	orders = orders[:limit]
+	last := orders[limit-1]
+	return orders, &repository.Cursor{Time: last.UploadedAt, UUID: last.UUID}, nil
+}
+
+func (p *OrderRepository) AccrueByNumber(ctx context.Context, number string, change *model.OrderStatusChange) (*model.Order, error) {
+	if change.Status != model.OrderStatusProcessed {
+		change.Accrual = 0
+	}
+
+	status, accrual := change.Status, change.Accrual
 	order := model.Order{
 		Number:  number,
 		Accrual: accrual,
@@ -120,33 +307,88 @@ func (p *OrderRepository) AccrueByNumber(ctx context.Context, number string, sta
 	}
 	defer tx.Rollback(ctx)
 
+	// Блокировка строки не дает параллельной доставке того же результата начислить баллы повторно
+	var current model.OrderStatus
 	err = tx.QueryRow(
 		ctx,
-		"update orders set status = $1, accrual = $2 where number = $3 returning uuid, user_uuid, uploaded_at",
-		status,
-		accrual,
+		"select uuid, status, user_uuid, uploaded_at from orders where number = $1 for update",
 		number,
 	).Scan(
 		&order.UUID,
+		&current,
 		&order.UserUUID,
 		&order.UploadedAt,
 	)
 
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = repository.ErrNotFound
+		}
 		return nil, err
 	}
 
+	if !current.CanTransitionTo(status) {
+		return nil, &repository.OrderTransitionError{Number: number, From: current, To: status}
+	}
+
 	_, err = tx.Exec(
 		ctx,
-		"update users set balance = balance + $1 where uuid = $2",
+		"update orders set status = $1, accrual = $2, updated_at = now() where uuid = $3",
+		status,
 		accrual,
-		order.UserUUID,
+		order.UUID,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
+	var responseCode *int
+	if change.ResponseCode != 0 {
+		responseCode = &change.ResponseCode
+	}
+
+	err = tx.QueryRow(
+		ctx,
+		`insert into order_status_history(order_uuid, status, accrual, source, response_code)
+		values($1, $2, $3, $4, $5) returning created_at`,
+		order.UUID,
+		status,
+		accrual,
+		change.Source,
+		responseCode,
+	).Scan(&change.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !accrual.IsZero() {
+		err = postLedgerEntry(
+			ctx,
+			tx,
+			&model.LedgerEntry{
+				Kind:      model.LedgerEntryAccrual,
+				Amount:    accrual,
+				UserUUID:  order.UserUUID,
+				Reference: number,
+			},
+			model.LedgerAccountAccrual,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Повторный опрос заказа без смены статуса не порождает событие, начисление возможно
+	// только вместе с переходом в PROCESSED
+	if current != status {
+		if err = notifyUserEvent(ctx, tx, model.UserEventOrder, order.UserUUID, order); err != nil {
+			return nil, err
+		}
+	}
+
 	err = tx.Commit(ctx)
 	if err != nil {
 		return nil, err
@@ -154,3 +396,115 @@ func (p *OrderRepository) AccrueByNumber(ctx context.Context, number string, sta
 
 	return &order, nil
 }
+
+func (p *OrderRepository) FindHistoryByNumber(ctx context.Context, number string) ([]*model.OrderStatusChange, error) {
+	history := make([]*model.OrderStatusChange, 0)
+	rows, err := p.pgxpool.Query(
+		ctx,
+		`select h.status, h.accrual, h.source, coalesce(h.response_code, 0), h.created_at
+		from order_status_history h
+		join orders o on o.uuid = h.order_uuid
+		where o.number = $1
+		order by h.created_at, h.uuid`,
+		number,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		change := &model.OrderStatusChange{}
+		err = rows.Scan(
+			&change.Status,
+			&change.Accrual,
+			&change.Source,
+			&change.ResponseCode,
+			&change.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, change)
+	}
+
+	return history, rows.Err()
+}
+
+func (p *OrderRepository) ClaimStale(
+	ctx context.Context,
+	olderThan time.Duration,
+	limit int,
+	handle func(order *model.Order) error,
+) (int, error) {
+	tx, err := p.pgxpool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(
+		ctx,
+		`select uuid, number, status, accrual, user_uuid, uploaded_at from orders
+		where status in ($1, $2) and updated_at < now() - $3::interval
+		order by updated_at
+		limit $4
+		for update skip locked`,
+		model.OrderStatusNew,
+		model.OrderStatusProcessing,
+		olderThan,
+		limit,
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	orders := make([]*model.Order, 0, limit)
+	for rows.Next() {
+		order := &model.Order{}
+		err = rows.Scan(
+			&order.UUID,
+			&order.Number,
+			&order.Status,
+			&order.Accrual,
+			&order.UserUUID,
+			&order.UploadedAt,
+		)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		orders = append(orders, order)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Заказы, которые не удалось обработать, остаются устаревшими и отбираются при следующей сверке
+	handled := make([]string, 0, len(orders))
+	var handleErr error
+	for _, order := range orders {
+		if handleErr = handle(order); handleErr != nil {
+			break
+		}
+		handled = append(handled, order.UUID)
+	}
+
+	if len(handled) > 0 {
+		_, err = tx.Exec(ctx, "update orders set updated_at = now() where uuid = any($1::uuid[])", handled)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return len(handled), handleErr
+}
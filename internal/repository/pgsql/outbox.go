@@ -0,0 +1,114 @@
+package pgsql
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+)
+
+type OutboxRepository struct {
+	pgxpool *pgxpool.Pool
+}
+
+func NewOutboxRepository(pgxpool *pgxpool.Pool) repository.Outbox {
+	return &OutboxRepository{pgxpool}
+}
+
+// OutboxOrderAddHook записывает сообщения о новых заказах в outbox в транзакции их добавления.
+func OutboxOrderAddHook(topic string) OrderAddHook {
+	return func(ctx context.Context, tx pgx.Tx, orders []*model.Order) error {
+		payloads := make([][]byte, 0, len(orders))
+		for _, order := range orders {
+			payloads = append(payloads, []byte(order.Number))
+		}
+
+		_, err := tx.Exec(
+			ctx,
+			"insert into outbox(topic, payload) select $1, unnest($2::bytea[])",
+			topic,
+			payloads,
+		)
+		return err
+	}
+}
+
+func (o *OutboxRepository) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*model.OutboxMessage, error) {
+	rows, err := o.pgxpool.Query(
+		ctx,
+		`update outbox set next_attempt_at = now() + $1::interval
+		where uuid in (
+			select uuid from outbox
+			where sent_at is null and next_attempt_at <= now()
+			order by created_at
+			limit $2
+			for update skip locked
+		)
+		returning uuid, topic, payload, attempts, created_at`,
+		lease,
+		limit,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	messages := make([]*model.OutboxMessage, 0, limit)
+	for rows.Next() {
+		message := &model.OutboxMessage{}
+		err = rows.Scan(
+			&message.UUID,
+			&message.Topic,
+			&message.Payload,
+			&message.Attempts,
+			&message.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
+
+func (o *OutboxRepository) MarkSent(ctx context.Context, uuid string) error {
+	_, err := o.pgxpool.Exec(ctx, "update outbox set sent_at = now() where uuid = $1", uuid)
+	return err
+}
+
+func (o *OutboxRepository) MarkFailed(ctx context.Context, uuid string, cause error) error {
+	_, err := o.pgxpool.Exec(
+		ctx,
+		`update outbox set attempts = attempts + 1, last_error = $1,
+		next_attempt_at = now() + least(power(2, attempts), 300) * interval '1 second'
+		where uuid = $2 and sent_at is null`,
+		cause.Error(),
+		uuid,
+	)
+	return err
+}
+
+func (o *OutboxRepository) CountPending(ctx context.Context) (int64, error) {
+	var count int64
+	err := o.pgxpool.QueryRow(ctx, "select count(*) from outbox where sent_at is null").Scan(&count)
+	return count, err
+}
+
+func (o *OutboxRepository) DeleteSent(ctx context.Context, olderThan time.Duration) (int64, error) {
+	tag, err := o.pgxpool.Exec(
+		ctx,
+		"delete from outbox where sent_at is not null and sent_at < now() - $1::interval",
+		olderThan,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,44 @@
+package pgsql
+
+import (
+	"fmt"
+
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+)
+
+// Дополняет запрос с условием where условиями и порядком страницы page по колонкам timeColumn и uuid.
+// Запрашивается на одну запись больше лимита, чтобы определить наличие следующей страницы.
+func keysetQuery(query string, args []any, page repository.Page, timeColumn string) (string, []any, int) {
+	limit := page.Limit
+	if limit <= 0 {
+		limit = repository.DefaultPageLimit
+	}
+	if limit > repository.MaxPageLimit {
+		limit = repository.MaxPageLimit
+	}
+
+	if !page.From.IsZero() {
+		args = append(args, page.From.UTC())
+		query += fmt.Sprintf(" and %s >= $%d", timeColumn, len(args))
+	}
+
+	if !page.To.IsZero() {
+		args = append(args, page.To.UTC())
+		query += fmt.Sprintf(" and %s < $%d", timeColumn, len(args))
+	}
+
+	direction, operator := "asc", ">"
+	if page.Desc {
+		direction, operator = "desc", "<"
+	}
+
+	if page.Cursor != nil {
+		args = append(args, page.Cursor.Time, page.Cursor.UUID)
+		query += fmt.Sprintf(" and (%s, uuid) %s ($%d, $%d)", timeColumn, operator, len(args)-1, len(args))
+	}
+
+	args = append(args, limit+1)
+	query += fmt.Sprintf(" order by %s %s, uuid %s limit $%d", timeColumn, direction, direction, len(args))
+
+	return query, args, limit
+}
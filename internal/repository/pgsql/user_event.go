@@ -0,0 +1,35 @@
+package pgsql
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+)
+
+// UserEventsChannel канал LISTEN/NOTIFY, в который публикуются события пользователей.
+const UserEventsChannel = "user_events"
+
+// Отправляет событие слушателям UserEventsChannel. Уведомление доставляется
+// только после фиксации транзакции tx и не доставляется при ее откате.
+func notifyUserEvent(ctx context.Context, tx pgx.Tx, eventType model.UserEventType, userUUID string, data any) error {
+	bData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	event := model.UserEvent{Type: eventType, UserUUID: userUUID, Data: bData}
+	if err = tx.QueryRow(ctx, "select nextval('user_events_seq')").Scan(&event.ID); err != nil {
+		return err
+	}
+
+	bEvent, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "select pg_notify($1, $2)", UserEventsChannel, string(bEvent))
+	return err
+}
@@ -2,7 +2,6 @@ package pgsql
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 
 	"github.com/jackc/pgerrcode"
@@ -12,6 +11,7 @@ import (
 	"github.com/casnerano/yandex-gophermart/internal/model"
 	"github.com/casnerano/yandex-gophermart/internal/repository"
 	"github.com/casnerano/yandex-gophermart/pkg/luhn"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
 )
 
 type WithdrawRepository struct {
@@ -22,11 +22,15 @@ func NewWithdrawRepository(pgxpool *pgxpool.Pool) repository.Withdraw {
 	return &WithdrawRepository{pgxpool}
 }
 
-func (w *WithdrawRepository) Add(ctx context.Context, orderNumber string, amount float64, userUUID string) (*model.Withdraw, error) {
+func (w *WithdrawRepository) Add(ctx context.Context, orderNumber string, amount money.Money, userUUID string) (*model.Withdraw, error) {
 	if !luhn.Checksum(orderNumber) {
 		return nil, repository.ErrOrderIncorrectNumber
 	}
 
+	if amount <= 0 {
+		return nil, repository.ErrWithdrawInvalidAmount
+	}
+
 	order := model.Withdraw{OrderNumber: orderNumber, Amount: amount, UserUUID: userUUID}
 
 	tx, err := w.pgxpool.Begin(ctx)
@@ -35,10 +39,10 @@ func (w *WithdrawRepository) Add(ctx context.Context, orderNumber string, amount
 	}
 	defer tx.Rollback(ctx)
 
-	var balance float64
+	var balance money.Money
 	err = tx.QueryRow(
 		ctx,
-		"select balance from users where uuid = $1",
+		"select balance from users where uuid = $1 for update",
 		userUUID,
 	).Scan(&balance)
 
@@ -50,17 +54,6 @@ func (w *WithdrawRepository) Add(ctx context.Context, orderNumber string, amount
 		return nil, repository.ErrWithdrawNotEnoughBalance
 	}
 
-	_, err = tx.Exec(
-		ctx,
-		"update users set balance = balance - $1 where uuid = $2",
-		amount,
-		userUUID,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
 	err = tx.QueryRow(
 		ctx,
 		"insert into withdraws(order_number, amount, user_uuid) values($1, $2, $3) returning uuid, processed_at",
@@ -80,6 +73,26 @@ func (w *WithdrawRepository) Add(ctx context.Context, orderNumber string, amount
 		return nil, err
 	}
 
+	err = postLedgerEntry(
+		ctx,
+		tx,
+		&model.LedgerEntry{
+			Kind:      model.LedgerEntryWithdrawal,
+			Amount:    -amount,
+			UserUUID:  userUUID,
+			Reference: orderNumber,
+		},
+		model.LedgerAccountWithdrawals,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = notifyUserEvent(ctx, tx, model.UserEventWithdrawal, userUUID, order); err != nil {
+		return nil, err
+	}
+
 	err = tx.Commit(ctx)
 	if err != nil {
 		return nil, err
@@ -119,21 +132,67 @@ func (w *WithdrawRepository) FindAllByUserUUID(ctx context.Context, userUUID str
 	return withdraws, nil
 }
 
-func (w *WithdrawRepository) TotalWithdrawnByUserUUID(ctx context.Context, userUUID string) (float64, error) {
-	var total sql.NullFloat64
+func (w *WithdrawRepository) FindPageByUserUUID(
+	ctx context.Context,
+	userUUID string,
+	page repository.Page,
+) ([]*model.Withdraw, *repository.Cursor, error) {
+	sql, args, limit := keysetQuery(
+		"select uuid, order_number, amount, user_uuid, processed_at from withdraws where user_uuid = $1",
+		[]any{userUUID},
+		page,
+		"processed_at",
+	)
+
+	withdraws := make([]*model.Withdraw, 0, limit)
+	rows, err := w.pgxpool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		withdraw := &model.Withdraw{}
+		err = rows.Scan(
+			&withdraw.UUID,
+			&withdraw.OrderNumber,
+			&withdraw.Amount,
+			&withdraw.UserUUID,
+			&withdraw.ProcessedAt,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+		withdraws = append(withdraws, withdraw)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(withdraws) <= limit {
+		return withdraws, nil, nil
+	}
+
+	withdraws = withdraws[:limit]
+	last := withdraws[limit-1]
+	return withdraws, &repository.Cursor{Time: last.ProcessedAt, UUID: last.UUID}, nil
+}
+
+func (w *WithdrawRepository) TotalWithdrawnByUserUUID(ctx context.Context, userUUID string) (money.Money, error) {
+	// Счет списаний в журнале учитывает сторнированные списания, в отличие от таблицы withdraws
+	var total money.Money
 	err := w.pgxpool.QueryRow(
 		ctx,
-		"select sum(amount) from withdraws where user_uuid = $1",
+		"select coalesce(sum(amount), 0) from ledger where user_uuid = $1 and account = $2",
 		userUUID,
+		model.LedgerAccountWithdrawals,
 	).Scan(&total)
 
 	if err != nil {
 		return 0, err
 	}
 
-	if !total.Valid {
-		return 0, nil
-	}
-
-	return total.Float64, nil
+	return total, nil
 }
@@ -0,0 +1,22 @@
+package pgsql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
+
+func TestWithdrawRepository_AddRejectsNonPositiveAmount(t *testing.T) {
+	// Проверка выполняется до обращения к базе, поэтому пул не нужен.
+	repo := NewWithdrawRepository(nil)
+
+	for _, amount := range []money.Money{0, -10000} {
+		_, err := repo.Add(context.Background(), "2377225624", amount, "user")
+		if !errors.Is(err, repository.ErrWithdrawInvalidAmount) {
+			t.Errorf("Add(%s) error = %v, want %v", amount, err, repository.ErrWithdrawInvalidAmount)
+		}
+	}
+}
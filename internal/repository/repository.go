@@ -3,8 +3,11 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
 )
 
 var (
@@ -12,9 +15,30 @@ var (
 	ErrNotFound     = errors.New("entity not found")
 
 	ErrOrderIncorrectNumber     = errors.New("incorrect order number")
+	ErrOrderStatusTransition    = errors.New("order status transition not allowed")
 	ErrWithdrawNotEnoughBalance = errors.New("not enough balance")
+	ErrWithdrawInvalidAmount    = errors.New("withdraw amount must be positive")
+
+	ErrLedgerEntryAlreadyReversed = errors.New("ledger entry already reversed")
+	ErrLedgerNegativeBalance      = errors.New("ledger entry leaves negative balance")
 )
 
+// OrderTransitionError недопустимый переход заказа между статусами,
+// например повторное начисление по заказу в финальном статусе.
+type OrderTransitionError struct {
+	Number string
+	From   model.OrderStatus
+	To     model.OrderStatus
+}
+
+func (e *OrderTransitionError) Error() string {
+	return fmt.Sprintf("%s: order \"%s\" %s -> %s", ErrOrderStatusTransition, e.Number, e.From, e.To)
+}
+
+func (e *OrderTransitionError) Is(target error) bool {
+	return target == ErrOrderStatusTransition
+}
+
 type User interface {
 	Add(ctx context.Context, login, password string) (*model.User, error)
 	FindByLogin(ctx context.Context, login string) (*model.User, error)
@@ -23,13 +47,59 @@ type User interface {
 
 type Order interface {
 	Add(ctx context.Context, number, userUUID string) (*model.Order, error)
+	// AddBatch добавляет заказы пользователя в одной транзакции. Возвращает добавленные заказы
+	// и заказы из numbers, загруженные ранее, в том числе другими пользователями.
+	AddBatch(ctx context.Context, numbers []string, userUUID string) ([]*model.Order, []*model.Order, error)
 	FindByNumber(ctx context.Context, number string) (*model.Order, error)
 	FindAllByUserUUID(ctx context.Context, userUUID string) ([]*model.Order, error)
-	AccrueByNumber(ctx context.Context, number string, status model.OrderStatus, accrual float64) (*model.Order, error)
+	// FindPageByUserUUID страница заказов пользователя и курсор следующей страницы,
+	// курсор равен nil на последней странице.
+	FindPageByUserUUID(ctx context.Context, userUUID string, query OrderQuery) ([]*model.Order, *Cursor, error)
+	// AccrueByNumber переводит заказ в статус из change и добавляет change в историю статусов.
+	// Баллы зачисляются только при переходе в PROCESSED, недопустимый переход возвращает OrderTransitionError.
+	AccrueByNumber(ctx context.Context, number string, change *model.OrderStatusChange) (*model.Order, error)
+	FindHistoryByNumber(ctx context.Context, number string) ([]*model.OrderStatusChange, error)
+	// ClaimStale передает обработчику заказы в не финальных статусах, не обновлявшиеся дольше olderThan,
+	// блокируя их от других экземпляров приложения. Обновленными отмечаются только успешно
+	// обработанные заказы, на первой ошибке обработка прерывается и ошибка возвращается
+	// вместе с количеством обработанных заказов.
+	ClaimStale(ctx context.Context, olderThan time.Duration, limit int, handle func(order *model.Order) error) (int, error)
 }
 
 type Withdraw interface {
-	Add(ctx context.Context, orderNumber string, amount float64, userUUID string) (*model.Withdraw, error)
+	Add(ctx context.Context, orderNumber string, amount money.Money, userUUID string) (*model.Withdraw, error)
 	FindAllByUserUUID(ctx context.Context, userUUID string) ([]*model.Withdraw, error)
-	TotalWithdrawnByUserUUID(ctx context.Context, userUUID string) (float64, error)
+	FindPageByUserUUID(ctx context.Context, userUUID string, page Page) ([]*model.Withdraw, *Cursor, error)
+	TotalWithdrawnByUserUUID(ctx context.Context, userUUID string) (money.Money, error)
+}
+
+type Ledger interface {
+	Adjust(ctx context.Context, userUUID string, amount money.Money, reason string) (*model.LedgerEntry, error)
+	Reverse(ctx context.Context, entryUUID string, reason string) (*model.LedgerEntry, error)
+	FindByUUID(ctx context.Context, uuid string) (*model.LedgerEntry, error)
+	FindAllByUserUUID(ctx context.Context, userUUID string) ([]*model.LedgerEntry, error)
+	FindDiscrepancies(ctx context.Context) ([]*model.LedgerDiscrepancy, error)
+}
+
+type IdempotencyKey interface {
+	// Acquire резервирует ключ за запросом на время lease. Если действующий ключ уже существует,
+	// возвращает его вместе с ошибкой ErrAlreadyExist. Ключ без сохраненного ответа
+	// по истечении lease резервируется повторно.
+	Acquire(ctx context.Context, userUUID, key, fingerprint string, lease time.Duration) (*model.IdempotencyKey, error)
+	// Complete сохраняет ответ на запрос и продлевает хранение ключа на ttl.
+	Complete(ctx context.Context, userUUID, key string, statusCode int, responseBody []byte, ttl time.Duration) error
+	Release(ctx context.Context, userUUID, key string) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type Outbox interface {
+	// ClaimPending выбирает готовые к отправке сообщения и откладывает их следующую попытку
+	// на lease, чтобы другие экземпляры приложения не отправляли их одновременно.
+	ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*model.OutboxMessage, error)
+	MarkSent(ctx context.Context, uuid string) error
+	// MarkFailed сохраняет причину неудачи и откладывает следующую попытку с растущей задержкой.
+	MarkFailed(ctx context.Context, uuid string, cause error) error
+	CountPending(ctx context.Context) (int64, error)
+	// DeleteSent удаляет сообщения, отправленные раньше чем olderThan назад.
+	DeleteSent(ctx context.Context, olderThan time.Duration) (int64, error)
 }
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/internal/service/accrual"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+const (
+	HeaderAccrualTimestamp = "X-Accrual-Timestamp"
+	HeaderAccrualSignature = "X-Accrual-Signature"
+
+	maxAccrualCallbackSize = 64 << 10
+)
+
+type AccrualCallback struct {
+	callbackService *accrual.Callback
+	logger          logger.Logger
+}
+
+func NewAccrualCallback(service *accrual.Callback, logger logger.Logger) *AccrualCallback {
+	return &AccrualCallback{callbackService: service, logger: logger}
+}
+
+func (a *AccrualCallback) PostAccrualCallback() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxAccrualCallbackSize))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		err = a.callbackService.Verify(body, r.Header.Get(HeaderAccrualTimestamp), r.Header.Get(HeaderAccrualSignature))
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			a.logger.Warning("Rejected accrual callback", err)
+			return
+		}
+
+		result := accrual.Result{}
+		if err = json.Unmarshal(body, &result); err != nil || result.Order == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if _, err = a.callbackService.Apply(r.Context(), result); err != nil {
+			switch {
+			case errors.Is(err, repository.ErrNotFound):
+				w.WriteHeader(http.StatusNotFound)
+			case errors.Is(err, accrual.ErrUnknownStatus):
+				w.WriteHeader(http.StatusUnprocessableEntity)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+
+			a.logger.Error("Failed apply accrual callback", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/casnerano/yandex-gophermart/internal/service/deadletter"
+	"github.com/casnerano/yandex-gophermart/internal/service/queue"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+const defaultDeadLettersLimit = 100
+
+type deadLetterResponse struct {
+	Order     string `json:"order"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+	DeadAt    string `json:"dead_at,omitempty"`
+}
+
+func newDeadLetterResponse(deadLetter *queue.DeadLetter) deadLetterResponse {
+	response := deadLetterResponse{
+		Order:     string(deadLetter.Body),
+		Attempts:  deadLetter.Attempts,
+		LastError: deadLetter.LastError,
+	}
+	if !deadLetter.DeadAt.IsZero() {
+		response.DeadAt = deadLetter.DeadAt.Format(time.RFC3339)
+	}
+	return response
+}
+
+type DeadLetter struct {
+	deadLetterService *deadletter.DeadLetter
+	logger            logger.Logger
+}
+
+func NewDeadLetter(service *deadletter.DeadLetter, logger logger.Logger) *DeadLetter {
+	return &DeadLetter{deadLetterService: service, logger: logger}
+}
+
+func (d *DeadLetter) GetDeadLetters() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultDeadLettersLimit
+		if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+			var err error
+			limit, err = strconv.Atoi(rawLimit)
+			if err != nil || limit <= 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		deadLetters, err := d.deadLetterService.FindAll(r.Context(), limit)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			d.logger.Error("Failed find dead letters", err)
+			return
+		}
+
+		if len(deadLetters) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		response := make([]deadLetterResponse, 0, len(deadLetters))
+		for _, deadLetter := range deadLetters {
+			response = append(response, newDeadLetterResponse(deadLetter))
+		}
+
+		bDeadLetters, err := json.Marshal(response)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			d.logger.Error("Failed marshaller dead letters", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, string(bDeadLetters))
+	}
+}
+
+func (d *DeadLetter) GetDeadLetter() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deadLetter, err := d.deadLetterService.FindByOrderNumber(r.Context(), chi.URLParam(r, "number"))
+		if err != nil {
+			if errors.Is(err, queue.ErrDeadLetterNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+			d.logger.Error("Failed find dead letter", err)
+			return
+		}
+
+		bDeadLetter, err := json.Marshal(newDeadLetterResponse(deadLetter))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			d.logger.Error("Failed marshaller dead letter", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, string(bDeadLetter))
+	}
+}
+
+func (d *DeadLetter) PostDeadLetterReplay() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := d.deadLetterService.Replay(r.Context(), chi.URLParam(r, "number"))
+		if err != nil {
+			if errors.Is(err, queue.ErrDeadLetterNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+			d.logger.Error("Failed replay dead letter", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (d *DeadLetter) DeleteDeadLetter() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := d.deadLetterService.Discard(r.Context(), chi.URLParam(r, "number"))
+		if err != nil {
+			if errors.Is(err, queue.ErrDeadLetterNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+			d.logger.Error("Failed discard dead letter", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/server/middleware"
+	"github.com/casnerano/yandex-gophermart/internal/service/events"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+const (
+	HeaderLastEventID = "Last-Event-ID"
+
+	eventsReconnectDelay   = 3 * time.Second
+	defaultEventsHeartbeat = 15 * time.Second
+)
+
+type Events struct {
+	hub       *events.Hub
+	heartbeat time.Duration
+	logger    logger.Logger
+}
+
+func NewEvents(hub *events.Hub, heartbeat time.Duration, logger logger.Logger) *Events {
+	if heartbeat <= 0 {
+		heartbeat = defaultEventsHeartbeat
+	}
+
+	return &Events{hub: hub, heartbeat: heartbeat, logger: logger}
+}
+
+// GetUserEvents поток изменений заказов и списаний пользователя в формате Server-Sent Events.
+// Клиент возобновляет поток с события из заголовка Last-Event-ID или параметра last_event_id.
+func (e *Events) GetUserEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userUUID, ok := middleware.GetUserUUID(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			e.logger.Error("Streaming is not supported by response writer")
+			return
+		}
+
+		rawLastEventID := r.Header.Get(HeaderLastEventID)
+		if rawLastEventID == "" {
+			rawLastEventID = r.URL.Query().Get("last_event_id")
+		}
+
+		var lastEventID int64
+		if rawLastEventID != "" {
+			var err error
+			if lastEventID, err = strconv.ParseInt(rawLastEventID, 10, 64); err != nil || lastEventID < 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		subscription, missed, err := e.hub.Subscribe(userUUID, lastEventID)
+		if err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		defer subscription.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprintf(w, "retry: %d\n\n", eventsReconnectDelay.Milliseconds())
+		for _, event := range missed {
+			writeUserEvent(w, event)
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(e.heartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, ok := <-subscription.Events():
+				// Подписка закрыта при остановке сервера или если клиент не успевал читать события
+				if !ok {
+					return
+				}
+				writeUserEvent(w, event)
+				flusher.Flush()
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func writeUserEvent(w io.Writer, event *model.UserEvent) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/casnerano/yandex-gophermart/internal/service/health"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+type Health struct {
+	healthService *health.Health
+	logger        logger.Logger
+}
+
+func NewHealth(service *health.Health, logger logger.Logger) *Health {
+	return &Health{healthService: service, logger: logger}
+}
+
+func (h *Health) GetHealth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		summary := h.healthService.Check(r.Context())
+
+		bSummary, err := json.Marshal(summary)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			h.logger.Error("Failed marshaller health summary", err)
+			return
+		}
+
+		if summary.Status == health.StatusDown {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		fmt.Fprint(w, string(bSummary))
+	}
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/internal/service/ledger"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
+
+type Ledger struct {
+	ledgerService *ledger.Ledger
+	logger        logger.Logger
+}
+
+func NewLedger(service *ledger.Ledger, logger logger.Logger) *Ledger {
+	return &Ledger{ledgerService: service, logger: logger}
+}
+
+func (l *Ledger) GetUserLedger() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userUUID := chi.URLParam(r, "uuid")
+
+		entries, err := l.ledgerService.FindAllByUserUUID(r.Context(), userUUID)
+		if errors.Is(err, repository.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			l.logger.Error("Failed find user ledger entries", err)
+			return
+		}
+
+		if len(entries) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		bEntries, err := json.Marshal(entries)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			l.logger.Error("Failed marshaller user ledger entries", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, string(bEntries))
+	}
+}
+
+func (l *Ledger) PostLedgerAdjustment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userUUID := chi.URLParam(r, "uuid")
+
+		adjustmentRequest := struct {
+			Amount money.Money `json:"amount"`
+			Reason string      `json:"reason"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&adjustmentRequest); err != nil {
+			if errors.Is(err, money.ErrInvalidFormat) || errors.Is(err, money.ErrOverflow) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+			} else {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			return
+		}
+
+		entry, err := l.ledgerService.Adjust(r.Context(), userUUID, adjustmentRequest.Amount, adjustmentRequest.Reason)
+		if err != nil {
+			switch {
+			case errors.Is(err, ledger.ErrZeroAmount), errors.Is(err, ledger.ErrEmptyReason):
+				w.WriteHeader(http.StatusUnprocessableEntity)
+			case errors.Is(err, repository.ErrNotFound):
+				w.WriteHeader(http.StatusNotFound)
+			case errors.Is(err, repository.ErrLedgerNegativeBalance):
+				w.WriteHeader(http.StatusConflict)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+				l.logger.Error("Failed ledger adjustment", err)
+			}
+			return
+		}
+
+		l.writeEntry(w, http.StatusCreated, entry)
+	}
+}
+
+func (l *Ledger) PostLedgerReversal() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entryUUID := chi.URLParam(r, "uuid")
+
+		reversalRequest := struct {
+			Reason string `json:"reason"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&reversalRequest); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		entry, err := l.ledgerService.Reverse(r.Context(), entryUUID, reversalRequest.Reason)
+		if err != nil {
+			switch {
+			case errors.Is(err, ledger.ErrEmptyReason):
+				w.WriteHeader(http.StatusUnprocessableEntity)
+			case errors.Is(err, repository.ErrNotFound):
+				w.WriteHeader(http.StatusNotFound)
+			case errors.Is(err, repository.ErrLedgerEntryAlreadyReversed),
+				errors.Is(err, repository.ErrLedgerNegativeBalance):
+				w.WriteHeader(http.StatusConflict)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+				l.logger.Error("Failed ledger reversal", err)
+			}
+			return
+		}
+
+		l.writeEntry(w, http.StatusCreated, entry)
+	}
+}
+
+func (l *Ledger) writeEntry(w http.ResponseWriter, statusCode int, entry any) {
+	bEntry, err := json.Marshal(entry)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		l.logger.Error("Failed marshaller ledger entry", err)
+		return
+	}
+
+	w.WriteHeader(statusCode)
+	fmt.Fprint(w, string(bEntry))
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/internal/server/middleware"
+	"github.com/casnerano/yandex-gophermart/internal/service/ledger"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
+
+const (
+	ledgerUserUUID  = "7f0c6c9e-5b1a-4f2e-9a57-0d4a6f1b2c3d"
+	ledgerEntryUUID = "0b6e2d5a-8c43-4b1f-a2e9-6d7c8e9f0a1b"
+)
+
+type ledgerEntries struct {
+	repository.Ledger
+	entries  map[string]*model.LedgerEntry
+	reversed map[string]bool
+}
+
+func (l *ledgerEntries) Adjust(_ context.Context, userUUID string, amount money.Money, reason string) (*model.LedgerEntry, error) {
+	if userUUID != ledgerUserUUID {
+		return nil, repository.ErrNotFound
+	}
+	if amount < -100000 {
+		return nil, repository.ErrLedgerNegativeBalance
+	}
+	entry := &model.LedgerEntry{UUID: "adjustment", Kind: model.LedgerEntryAdjustment, Amount: amount, UserUUID: userUUID, Reference: reason}
+	l.entries[entry.UUID] = entry
+	return entry, nil
+}
+
+func (l *ledgerEntries) Reverse(_ context.Context, entryUUID string, reason string) (*model.LedgerEntry, error) {
+	original, found := l.entries[entryUUID]
+	if !found {
+		return nil, repository.ErrNotFound
+	}
+	if l.reversed[entryUUID] {
+		return nil, repository.ErrLedgerEntryAlreadyReversed
+	}
+	l.reversed[entryUUID] = true
+	return &model.LedgerEntry{Kind: model.LedgerEntryReversal, Amount: -original.Amount, Reference: reason, ReversalOf: entryUUID}, nil
+}
+
+func TestLedger_AdminRoutes(t *testing.T) {
+	repo := &ledgerEntries{
+		entries:  map[string]*model.LedgerEntry{ledgerEntryUUID: {UUID: ledgerEntryUUID, Amount: 50000}},
+		reversed: map[string]bool{},
+	}
+	ledgerHandler := NewLedger(ledger.New(repo, logger.New()), logger.New())
+
+	router := chi.NewRouter()
+	router.Use(middleware.AdminAuthenticator("admin"))
+	router.Post("/admin/users/{uuid}/ledger/adjustments", ledgerHandler.PostLedgerAdjustment())
+	router.Post("/admin/ledger/{uuid}/reversal", ledgerHandler.PostLedgerReversal())
+
+	tests := []struct {
+		name  string
+		token string
+		path  string
+		body  string
+		code  int
+	}{
+		{name: "without token", path: "/admin/users/" + ledgerUserUUID + "/ledger/adjustments", body: `{"amount":10,"reason":"bonus"}`, code: http.StatusUnauthorized},
+		{name: "adjustment", token: "admin", path: "/admin/users/" + ledgerUserUUID + "/ledger/adjustments", body: `{"amount":-10.5,"reason":" support ticket "}`, code: http.StatusCreated},
+		{name: "zero adjustment", token: "admin", path: "/admin/users/" + ledgerUserUUID + "/ledger/adjustments", body: `{"amount":0,"reason":"bonus"}`, code: http.StatusUnprocessableEntity},
+		{name: "adjustment without reason", token: "admin", path: "/admin/users/" + ledgerUserUUID + "/ledger/adjustments", body: `{"amount":10}`, code: http.StatusUnprocessableEntity},
+		{name: "adjustment below zero balance", token: "admin", path: "/admin/users/" + ledgerUserUUID + "/ledger/adjustments", body: `{"amount":-1000.01,"reason":"chargeback"}`, code: http.StatusConflict},
+		{name: "adjustment of unknown user", token: "admin", path: "/admin/users/" + ledgerEntryUUID + "/ledger/adjustments", body: `{"amount":10,"reason":"bonus"}`, code: http.StatusNotFound},
+		{name: "adjustment with malformed uuid", token: "admin", path: "/admin/users/user/ledger/adjustments", body: `{"amount":10,"reason":"bonus"}`, code: http.StatusNotFound},
+		{name: "reversal", token: "admin", path: "/admin/ledger/" + ledgerEntryUUID + "/reversal", body: `{"reason":"fraud"}`, code: http.StatusCreated},
+		{name: "repeated reversal", token: "admin", path: "/admin/ledger/" + ledgerEntryUUID + "/reversal", body: `{"reason":"fraud"}`, code: http.StatusConflict},
+		{name: "reversal of unknown entry", token: "admin", path: "/admin/ledger/" + ledgerUserUUID + "/reversal", body: `{"reason":"fraud"}`, code: http.StatusNotFound},
+		{name: "reversal without reason", token: "admin", path: "/admin/ledger/" + ledgerEntryUUID + "/reversal", body: `{}`, code: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.code {
+				t.Errorf("status = %d, want %d", w.Code, tt.code)
+			}
+		})
+	}
+
+	adjustment, found := repo.entries["adjustment"]
+	if !found {
+		t.Fatal("adjustment was not posted")
+	}
+	if adjustment.Amount != -1050 || adjustment.Reference != "support ticket" {
+		t.Errorf("adjustment = %s %q, want -10.50 \"support ticket\"", adjustment.Amount, adjustment.Reference)
+	}
+}
+
+func TestLedger_PostLedgerReversalResponse(t *testing.T) {
+	repo := &ledgerEntries{
+		entries:  map[string]*model.LedgerEntry{ledgerEntryUUID: {UUID: ledgerEntryUUID, Amount: 50000}},
+		reversed: map[string]bool{},
+	}
+	router := chi.NewRouter()
+	router.Post("/admin/ledger/{uuid}/reversal", NewLedger(ledger.New(repo, logger.New()), logger.New()).PostLedgerReversal())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/ledger/"+ledgerEntryUUID+"/reversal", strings.NewReader(`{"reason":"fraud"}`)))
+
+	var entry struct {
+		Kind       model.LedgerEntryKind `json:"kind"`
+		Amount     money.Money           `json:"amount"`
+		ReversalOf string                `json:"reversal_of"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &entry); err != nil {
+		t.Fatalf("response %q: %v", w.Body.String(), err)
+	}
+	if entry.Kind != model.LedgerEntryReversal || entry.Amount != -50000 || entry.ReversalOf != ledgerEntryUUID {
+		t.Errorf("reversal = %+v", entry)
+	}
+}
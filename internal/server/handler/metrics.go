@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/casnerano/yandex-gophermart/internal/service/metrics"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+type Metrics struct {
+	metricsService *metrics.Metrics
+	logger         logger.Logger
+}
+
+func NewMetrics(service *metrics.Metrics, logger logger.Logger) *Metrics {
+	return &Metrics{metricsService: service, logger: logger}
+}
+
+func (m *Metrics) GetMetrics() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		if err := m.metricsService.Write(&buf); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			m.logger.Error("Failed collect metrics", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.WriteHeader(http.StatusOK)
+		_, _ = buf.WriteTo(w)
+	}
+}
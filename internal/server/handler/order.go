@@ -1,18 +1,53 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
 	"github.com/casnerano/yandex-gophermart/internal/repository"
 	"github.com/casnerano/yandex-gophermart/internal/server/middleware"
 	"github.com/casnerano/yandex-gophermart/internal/service/order"
 	"github.com/casnerano/yandex-gophermart/pkg/logger"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
+
+type orderDetailsResponse struct {
+	Number     string                     `json:"number"`
+	Status     model.OrderStatus          `json:"status"`
+	Accrual    money.Money                `json:"accrual,omitempty"`
+	UploadedAt string                     `json:"uploaded_at"`
+	History    []*model.OrderStatusChange `json:"history"`
+}
+
+const (
+	maxOrdersBatchSize     = 1000
+	maxOrdersBatchBodySize = 1 << 20
 )
 
+var errInvalidOrderNumbers = errors.New("order numbers must be strings or numbers")
+
+var orderUploadStatuses = map[int]string{
+	http.StatusAccepted:            "accepted",
+	http.StatusOK:                  "already_uploaded",
+	http.StatusConflict:            "conflict",
+	http.StatusUnprocessableEntity: "invalid",
+}
+
+type orderUploadResponse struct {
+	Number string `json:"number"`
+	Status string `json:"status"`
+	Code   int    `json:"code"`
+}
+
 type Order struct {
 	orderService *order.Order
 	logger       logger.Logger
@@ -37,23 +72,67 @@ func (o *Order) PostUserOrder() http.HandlerFunc {
 		}
 
 		_, err = o.orderService.Add(r.Context(), string(orderNumber), userUUID)
+		w.WriteHeader(orderAddStatusCode(err))
 		if err != nil {
-			switch {
-			case errors.Is(err, repository.ErrOrderIncorrectNumber):
-				w.WriteHeader(http.StatusUnprocessableEntity)
-			case errors.Is(err, order.ErrAlreadyUploaded):
-				w.WriteHeader(http.StatusOK)
-			case errors.Is(err, order.ErrAlreadyUploadedByAnother):
-				w.WriteHeader(http.StatusConflict)
-			default:
-				w.WriteHeader(http.StatusInternalServerError)
-			}
-
 			o.logger.Error("Failed to add order", err)
+		}
+	}
+}
+
+// PostUserOrdersBatch загрузка заказов JSON-массивом или текстом с номером на каждой строке.
+// Результат каждого номера содержит код ответа, который вернула бы загрузка этого номера по одному.
+func (o *Order) PostUserOrdersBatch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userUUID, ok := middleware.GetUserUUID(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxOrdersBatchBodySize))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		numbers, err := parseOrderNumbers(body)
+		if err != nil || len(numbers) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if len(numbers) > maxOrdersBatchSize {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			fmt.Fprintf(w, "No more than %d orders per batch allowed", maxOrdersBatchSize)
+			return
+		}
+
+		results, err := o.orderService.AddBatch(r.Context(), numbers, userUUID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			o.logger.Error("Failed to add orders batch", err)
+			return
+		}
+
+		response := make([]orderUploadResponse, 0, len(results))
+		for _, result := range results {
+			code := orderAddStatusCode(result.Err)
+			response = append(response, orderUploadResponse{
+				Number: result.Number,
+				Status: orderUploadStatuses[code],
+				Code:   code,
+			})
+		}
+
+		bResponse, err := json.Marshal(response)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			o.logger.Error("Failed marshaller orders batch results", err)
 			return
 		}
 
-		w.WriteHeader(http.StatusAccepted)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, string(bResponse))
 	}
 }
 
@@ -65,7 +144,22 @@ func (o *Order) GetUserOrders() http.HandlerFunc {
 			return
 		}
 
-		orders, err := o.orderService.FindAllByUserUUID(r.Context(), userUUID)
+		var (
+			orders []*model.Order
+			next   *repository.Cursor
+			err    error
+		)
+		if query := r.URL.Query(); isPageRequested(query) {
+			orderQuery, parseErr := parseOrderQuery(query)
+			if parseErr != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			orders, next, err = o.orderService.FindPageByUserUUID(r.Context(), userUUID, orderQuery)
+		} else {
+			orders, err = o.orderService.FindAllByUserUUID(r.Context(), userUUID)
+		}
+
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			o.logger.Error("Failed find user orders", err)
@@ -84,7 +178,98 @@ func (o *Order) GetUserOrders() http.HandlerFunc {
 			return
 		}
 
+		setNextPage(w, r, next)
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprint(w, string(bOrders))
 	}
 }
+
+// GetUserOrder заказ пользователя с историей статусов. На чужой заказ отвечает
+// так же, как на несуществующий, чтобы не раскрывать номера заказов других пользователей.
+func (o *Order) GetUserOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userUUID, ok := middleware.GetUserUUID(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		found, history, err := o.orderService.FindUserOrder(r.Context(), chi.URLParam(r, "number"), userUUID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) || errors.Is(err, order.ErrNotOwner) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+			o.logger.Error("Failed find user order", err)
+			return
+		}
+
+		bOrder, err := json.Marshal(orderDetailsResponse{
+			Number:     found.Number,
+			Status:     found.Status,
+			Accrual:    found.Accrual,
+			UploadedAt: found.UploadedAt.Format(time.RFC3339),
+			History:    history,
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			o.logger.Error("Failed marshaller user order", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, string(bOrder))
+	}
+}
+
+// Код ответа на добавление заказа, общий для загрузки по одному и пакетом.
+func orderAddStatusCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusAccepted
+	case errors.Is(err, repository.ErrOrderIncorrectNumber):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, order.ErrAlreadyUploaded):
+		return http.StatusOK
+	case errors.Is(err, order.ErrAlreadyUploadedByAnother):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Номера заказов из JSON-массива строк или чисел, либо из текста с номером на каждой строке.
+func parseOrderNumbers(body []byte) ([]string, error) {
+	body = bytes.TrimSpace(body)
+	if !bytes.HasPrefix(body, []byte("[")) {
+		numbers := make([]string, 0)
+		for _, line := range strings.Split(string(body), "\n") {
+			if number := strings.TrimSpace(line); number != "" {
+				numbers = append(numbers, number)
+			}
+		}
+		return numbers, nil
+	}
+
+	var values []any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+
+	numbers := make([]string, 0, len(values))
+	for _, value := range values {
+		switch number := value.(type) {
+		case string:
+			numbers = append(numbers, strings.TrimSpace(number))
+		case json.Number:
+			numbers = append(numbers, number.String())
+		default:
+			return nil, errInvalidOrderNumbers
+		}
+	}
+	return numbers, nil
+}
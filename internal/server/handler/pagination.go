@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+)
+
+const HeaderNextCursor = "X-Next-Cursor"
+
+var errInvalidPageQuery = errors.New("invalid page query")
+
+var pageParams = []string{"limit", "cursor", "status", "from", "to", "sort"}
+
+// Постраничная выборка включается любым из параметров страницы.
+// Без них списки возвращаются целиком, как того требует спецификация.
+func isPageRequested(query url.Values) bool {
+	for _, param := range pageParams {
+		if query.Has(param) {
+			return true
+		}
+	}
+	return false
+}
+
+// Параметры страницы: limit, cursor из предыдущего ответа, from и to в RFC3339, sort asc или desc.
+func parsePage(query url.Values) (repository.Page, error) {
+	page := repository.Page{Limit: repository.DefaultPageLimit}
+
+	if rawLimit := query.Get("limit"); rawLimit != "" {
+		limit, err := strconv.Atoi(rawLimit)
+		if err != nil || limit <= 0 || limit > repository.MaxPageLimit {
+			return page, errInvalidPageQuery
+		}
+		page.Limit = limit
+	}
+
+	if rawCursor := query.Get("cursor"); rawCursor != "" {
+		cursor, err := repository.DecodeCursor(rawCursor)
+		if err != nil {
+			return page, err
+		}
+		page.Cursor = cursor
+	}
+
+	for param, bound := range map[string]*time.Time{"from": &page.From, "to": &page.To} {
+		if rawTime := query.Get(param); rawTime != "" {
+			parsed, err := time.Parse(time.RFC3339, rawTime)
+			if err != nil {
+				return page, errInvalidPageQuery
+			}
+			*bound = parsed
+		}
+	}
+
+	switch query.Get("sort") {
+	case "", "asc":
+	case "desc":
+		page.Desc = true
+	default:
+		return page, errInvalidPageQuery
+	}
+
+	return page, nil
+}
+
+// Статусы задаются повторяющимся параметром status или через запятую.
+func parseOrderQuery(query url.Values) (repository.OrderQuery, error) {
+	page, err := parsePage(query)
+	if err != nil {
+		return repository.OrderQuery{}, err
+	}
+
+	orderQuery := repository.OrderQuery{Page: page}
+	for _, rawStatuses := range query["status"] {
+		for _, rawStatus := range strings.Split(rawStatuses, ",") {
+			status := model.OrderStatus(strings.ToUpper(strings.TrimSpace(rawStatus)))
+			switch status {
+			case model.OrderStatusNew, model.OrderStatusProcessing, model.OrderStatusInvalid, model.OrderStatusProcessed:
+				orderQuery.Statuses = append(orderQuery.Statuses, status)
+			default:
+				return orderQuery, errInvalidPageQuery
+			}
+		}
+	}
+
+	return orderQuery, nil
+}
+
+// Ссылка на следующую страницу повторяет параметры текущего запроса с новым курсором.
+func setNextPage(w http.ResponseWriter, r *http.Request, cursor *repository.Cursor) {
+	if cursor == nil {
+		return
+	}
+
+	encoded := cursor.Encode()
+	next := *r.URL
+	query := next.Query()
+	query.Set("cursor", encoded)
+	next.RawQuery = query.Encode()
+
+	w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	w.Header().Set(HeaderNextCursor, encoded)
+}
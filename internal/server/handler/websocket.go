@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/casnerano/yandex-gophermart/internal/server/middleware"
+	"github.com/casnerano/yandex-gophermart/internal/service/notification"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+const (
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = wsPongWait * 9 / 10
+	wsCloseWait      = time.Second
+	wsMaxMessageSize = 512
+
+	wsMessagePing = "ping"
+	wsMessagePong = "pong"
+)
+
+type wsClientMessage struct {
+	Type string `json:"type"`
+}
+
+type WebSocket struct {
+	notifier *notification.Notifier
+	upgrader websocket.Upgrader
+	logger   logger.Logger
+}
+
+func NewWebSocket(notifier *notification.Notifier, logger logger.Logger) *WebSocket {
+	return &WebSocket{
+		notifier: notifier,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		},
+		logger: logger,
+	}
+}
+
+// GetUserWS постоянное соединение с уведомлениями об изменении баланса, заказов и списаниях.
+//
+// Сервер отправляет ping каждые wsPingPeriod и закрывает соединение, если не получает pong за wsPongWait.
+// Клиенты без доступа к управляющим фреймам могут отправить сообщение {"type":"ping"} и получить {"type":"pong"}.
+// Соединение, не успевающее получать уведомления, закрывается с кодом 1013, при остановке сервера с кодом 1001.
+func (ws *WebSocket) GetUserWS() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userUUID, ok := middleware.GetUserUUID(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		session, err := ws.notifier.Open(userUUID)
+		if err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		defer session.Close()
+
+		// При ошибке Upgrade сам отвечает клиенту
+		conn, err := ws.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			ws.logger.Warning("Failed upgrade websocket connection", err)
+			return
+		}
+		defer conn.Close()
+
+		pings := make(chan struct{}, 1)
+		readDone := make(chan struct{})
+		go ws.read(conn, pings, readDone)
+
+		ticker := time.NewTicker(wsPingPeriod)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-readDone:
+				return
+			case message, ok := <-session.Messages():
+				if !ok {
+					ws.close(conn, session.Err(), readDone)
+					return
+				}
+				if err = ws.write(conn, message); err != nil {
+					return
+				}
+			case <-pings:
+				if err = ws.write(conn, wsClientMessage{Type: wsMessagePong}); err != nil {
+					return
+				}
+			case <-ticker.C:
+				if err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Читает сообщения клиента до закрытия соединения. Запись выполняет только обработчик,
+// поэтому ответ на ping передается ему через pings.
+func (ws *WebSocket) read(conn *websocket.Conn, pings chan<- struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		message := wsClientMessage{}
+		if err = json.Unmarshal(data, &message); err != nil || message.Type != wsMessagePing {
+			continue
+		}
+
+		_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		select {
+		case pings <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (ws *WebSocket) write(conn *websocket.Conn, message any) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return conn.WriteJSON(message)
+}
+
+// Отправляет фрейм закрытия и недолго ожидает ответного закрытия от клиента.
+func (ws *WebSocket) close(conn *websocket.Conn, reason error, readDone <-chan struct{}) {
+	code, text := websocket.CloseGoingAway, "server shutdown"
+	if errors.Is(reason, notification.ErrSlowConsumer) {
+		code, text = websocket.CloseTryAgainLater, "too slow to receive notifications"
+	}
+
+	err := conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(wsWriteWait))
+	if err != nil {
+		return
+	}
+
+	select {
+	case <-readDone:
+	case <-time.After(wsCloseWait):
+	}
+}
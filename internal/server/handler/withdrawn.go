@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/casnerano/yandex-gophermart/internal/model"
 	"github.com/casnerano/yandex-gophermart/internal/repository"
 	"github.com/casnerano/yandex-gophermart/internal/server/middleware"
 	"github.com/casnerano/yandex-gophermart/internal/service/withdraw"
 	"github.com/casnerano/yandex-gophermart/pkg/logger"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
 )
 
 type Withdraw struct {
@@ -30,17 +32,26 @@ func (wd *Withdraw) PostUserBalanceWithdraw() http.HandlerFunc {
 		}
 
 		withdrawRequest := struct {
-			Order string  `json:"order"`
-			Sum   float64 `json:"sum"`
+			Order string      `json:"order"`
+			Sum   money.Money `json:"sum"`
 		}{}
 
 		err := json.NewDecoder(r.Body).Decode(&withdrawRequest)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			if errors.Is(err, money.ErrInvalidFormat) || errors.Is(err, money.ErrOverflow) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+			} else {
+				w.WriteHeader(http.StatusBadRequest)
+			}
 			wd.logger.Error("Failed withdrawn request unmarshall", err)
 			return
 		}
 
+		if withdrawRequest.Sum <= 0 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+
 		_, err = wd.withdrawService.Add(
 			r.Context(),
 			withdrawRequest.Order,
@@ -52,7 +63,8 @@ func (wd *Withdraw) PostUserBalanceWithdraw() http.HandlerFunc {
 			switch {
 			case errors.Is(err, repository.ErrWithdrawNotEnoughBalance):
 				w.WriteHeader(http.StatusPaymentRequired)
-			case errors.Is(err, repository.ErrOrderIncorrectNumber):
+			case errors.Is(err, repository.ErrOrderIncorrectNumber),
+				errors.Is(err, repository.ErrWithdrawInvalidAmount):
 				w.WriteHeader(http.StatusUnprocessableEntity)
 			default:
 				w.WriteHeader(http.StatusInternalServerError)
@@ -74,7 +86,22 @@ func (wd *Withdraw) GetUserWithdrawals() http.HandlerFunc {
 			return
 		}
 
-		withdrawals, err := wd.withdrawService.FindAllByUserUUID(r.Context(), userUUID)
+		var (
+			withdrawals []*model.Withdraw
+			next        *repository.Cursor
+			err         error
+		)
+		if query := r.URL.Query(); isPageRequested(query) {
+			page, parseErr := parsePage(query)
+			if parseErr != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			withdrawals, next, err = wd.withdrawService.FindPageByUserUUID(r.Context(), userUUID, page)
+		} else {
+			withdrawals, err = wd.withdrawService.FindAllByUserUUID(r.Context(), userUUID)
+		}
+
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			wd.logger.Error("Failed find user withdrawals", err)
@@ -93,6 +120,7 @@ func (wd *Withdraw) GetUserWithdrawals() http.HandlerFunc {
 			return
 		}
 
+		setNextPage(w, r, next)
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprint(w, string(bWithdrawals))
 	}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/internal/server/middleware"
+	"github.com/casnerano/yandex-gophermart/internal/service/token"
+	"github.com/casnerano/yandex-gophermart/internal/service/withdraw"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
+
+type withdraws struct {
+	repository.Withdraw
+	added []money.Money
+}
+
+func (w *withdraws) Add(_ context.Context, orderNumber string, amount money.Money, userUUID string) (*model.Withdraw, error) {
+	w.added = append(w.added, amount)
+	return &model.Withdraw{OrderNumber: orderNumber, Amount: amount, UserUUID: userUUID}, nil
+}
+
+func TestWithdraw_PostUserBalanceWithdraw(t *testing.T) {
+	jwt, err := token.NewJWT("user", "secret")
+	if err != nil {
+		t.Fatalf("NewJWT() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		body  string
+		code  int
+		added bool
+	}{
+		{name: "valid", body: `{"order":"2377225624","sum":751.5}`, code: http.StatusOK, added: true},
+		{name: "negative sum", body: `{"order":"2377225624","sum":-100}`, code: http.StatusUnprocessableEntity},
+		{name: "zero sum", body: `{"order":"2377225624","sum":0}`, code: http.StatusUnprocessableEntity},
+		{name: "missing sum", body: `{"order":"2377225624"}`, code: http.StatusUnprocessableEntity},
+		{name: "invalid sum", body: `{"order":"2377225624","sum":"1.2.3"}`, code: http.StatusUnprocessableEntity},
+		{name: "malformed body", body: `{"order":`, code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &withdraws{}
+			h := middleware.JWTAuthenticator("secret")(NewWithdraw(withdraw.New(nil, repo), logger.New()).PostUserBalanceWithdraw())
+
+			r := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(tt.body))
+			r.Header.Set("Authorization", "Bearer "+jwt)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.code {
+				t.Errorf("status = %d, want %d", w.Code, tt.code)
+			}
+			if added := len(repo.added) > 0; added != tt.added {
+				t.Errorf("withdraw added = %v, want %v", added, tt.added)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/casnerano/yandex-gophermart/internal/service/idempotency"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rw *recordingResponseWriter) WriteHeader(statusCode int) {
+	rw.statusCode = statusCode
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rw *recordingResponseWriter) Write(b []byte) (int, error) {
+	if rw.statusCode == 0 {
+		rw.statusCode = http.StatusOK
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// Idempotency воспроизводит сохраненный ответ на повторный запрос с тем же заголовком Idempotency-Key.
+// Должен подключаться после JWTAuthenticator, ключи хранятся в разрезе пользователя.
+func Idempotency(service *idempotency.Idempotency, logger logger.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userUUID, ok := GetUserUUID(r.Context())
+			if !ok || len(key) > 255 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			fingerprint := idempotency.Fingerprint(r.Method, r.URL.Path, body)
+			stored, err := service.Begin(r.Context(), userUUID, key, fingerprint)
+			if err != nil {
+				switch {
+				case errors.Is(err, idempotency.ErrKeyReused):
+					w.WriteHeader(http.StatusUnprocessableEntity)
+				case errors.Is(err, idempotency.ErrInProgress):
+					w.WriteHeader(http.StatusConflict)
+				default:
+					logger.Error("Failed to acquire idempotency key", err)
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+				return
+			}
+
+			if stored != nil {
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(stored.StatusCode)
+				_, _ = w.Write(stored.ResponseBody)
+				return
+			}
+
+			// Ответ сохраняется независимо от отмены запроса клиентом
+			ctx := context.Background()
+			release := func() {
+				if err := service.Release(ctx, userUUID, key); err != nil {
+					logger.Error("Failed to release idempotency key", err)
+				}
+			}
+
+			// При панике обработчика ключ освобождается, чтобы запрос можно было повторить сразу
+			handled := false
+			defer func() {
+				if !handled {
+					release()
+				}
+			}()
+
+			recorder := &recordingResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+			handled = true
+
+			if recorder.statusCode == 0 {
+				recorder.statusCode = http.StatusOK
+			}
+
+			if recorder.statusCode >= http.StatusInternalServerError {
+				release()
+				return
+			}
+
+			if err = service.Complete(ctx, userUUID, key, recorder.statusCode, recorder.body.Bytes()); err != nil {
+				logger.Error("Failed to store idempotent response", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/internal/service/idempotency"
+	"github.com/casnerano/yandex-gophermart/internal/service/token"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+type idempotencyKeys struct {
+	mu   sync.Mutex
+	now  time.Time
+	keys map[string]*model.IdempotencyKey
+}
+
+func newIdempotencyKeys() *idempotencyKeys {
+	return &idempotencyKeys{now: time.Now(), keys: make(map[string]*model.IdempotencyKey)}
+}
+
+func (k *idempotencyKeys) advance(d time.Duration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.now = k.now.Add(d)
+}
+
+func (k *idempotencyKeys) Acquire(_ context.Context, userUUID, key, fingerprint string, lease time.Duration) (*model.IdempotencyKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if stored, found := k.keys[userUUID+key]; found && !stored.ExpiresAt.Before(k.now) {
+		copied := *stored
+		return &copied, repository.ErrAlreadyExist
+	}
+
+	k.keys[userUUID+key] = &model.IdempotencyKey{
+		Key:         key,
+		UserUUID:    userUUID,
+		Fingerprint: fingerprint,
+		CreatedAt:   k.now,
+		ExpiresAt:   k.now.Add(lease),
+	}
+	return k.keys[userUUID+key], nil
+}
+
+func (k *idempotencyKeys) Complete(_ context.Context, userUUID, key string, statusCode int, responseBody []byte, ttl time.Duration) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	stored := k.keys[userUUID+key]
+	stored.StatusCode = statusCode
+	stored.ResponseBody = append([]byte(nil), responseBody...)
+	stored.ExpiresAt = k.now.Add(ttl)
+	return nil
+}
+
+func (k *idempotencyKeys) Release(_ context.Context, userUUID, key string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if stored, found := k.keys[userUUID+key]; found && !stored.Completed() {
+		delete(k.keys, userUUID+key)
+	}
+	return nil
+}
+
+func (k *idempotencyKeys) DeleteExpired(context.Context) (int64, error) {
+	return 0, nil
+}
+
+type idempotencyTest struct {
+	t       *testing.T
+	jwt     string
+	handler http.Handler
+}
+
+func newIdempotencyTest(t *testing.T, keys repository.IdempotencyKey, next http.HandlerFunc) *idempotencyTest {
+	jwt, err := token.NewJWT("user", "secret")
+	if err != nil {
+		t.Fatalf("NewJWT() error = %v", err)
+	}
+
+	service := idempotency.New(keys, 24*time.Hour, time.Minute, logger.New())
+	handler := JWTAuthenticator("secret")(Idempotency(service, logger.New())(next))
+	return &idempotencyTest{t: t, jwt: jwt, handler: handler}
+}
+
+func (it *idempotencyTest) do(key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+it.jwt)
+	r.Header.Set(IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+	it.handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotency_Replay(t *testing.T) {
+	calls := 0
+	it := newIdempotencyTest(t, newIdempotencyKeys(), func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusPaymentRequired)
+		_, _ = w.Write([]byte(`{"error":"not enough balance"}`))
+	})
+
+	first := it.do("key", `{"order":"2377225624","sum":751}`)
+	second := it.do("key", `{"order":"2377225624","sum":751}`)
+
+	if calls != 1 {
+		t.Errorf("handler calls = %d, want 1", calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("replayed %d %q, want %d %q", second.Code, second.Body.String(), first.Code, first.Body.String())
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replayed response without Idempotent-Replayed header")
+	}
+}
+
+func TestIdempotency_FingerprintMismatch(t *testing.T) {
+	it := newIdempotencyTest(t, newIdempotencyKeys(), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	it.do("key", `{"order":"2377225624","sum":751}`)
+	if w := it.do("key", `{"order":"2377225624","sum":752}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestIdempotency_ConcurrentDuplicate(t *testing.T) {
+	started, finish := make(chan struct{}), make(chan struct{})
+	it := newIdempotencyTest(t, newIdempotencyKeys(), func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+		w.WriteHeader(http.StatusOK)
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- it.do("key", `{"order":"2377225624","sum":751}`)
+	}()
+	<-started
+
+	if w := it.do("key", `{"order":"2377225624","sum":751}`); w.Code != http.StatusConflict {
+		t.Errorf("duplicate status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	close(finish)
+	if w := <-done; w.Code != http.StatusOK {
+		t.Errorf("first status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestIdempotency_ExpiredLease(t *testing.T) {
+	keys := newIdempotencyKeys()
+	calls := 0
+	it := newIdempotencyTest(t, keys, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// Ключ зарезервирован процессом, упавшим до сохранения ответа
+	if _, err := keys.Acquire(context.Background(), "user", "key", idempotency.Fingerprint(
+		http.MethodPost, "/api/user/balance/withdraw", []byte(`{"order":"2377225624","sum":751}`),
+	), time.Minute); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	if w := it.do("key", `{"order":"2377225624","sum":751}`); w.Code != http.StatusConflict {
+		t.Errorf("status within lease = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	keys.advance(2 * time.Minute)
+	if w := it.do("key", `{"order":"2377225624","sum":751}`); w.Code != http.StatusOK || calls != 1 {
+		t.Errorf("status after lease = %d, handler calls = %d, want %d and 1", w.Code, calls, http.StatusOK)
+	}
+
+	// Сохраненный ответ воспроизводится до истечения ttl, а не lease
+	keys.advance(2 * time.Minute)
+	if w := it.do("key", `{"order":"2377225624","sum":751}`); w.Header().Get("Idempotent-Replayed") != "true" || calls != 1 {
+		t.Errorf("response was not replayed after lease, handler calls = %d", calls)
+	}
+}
+
+func TestIdempotency_PanicReleasesKey(t *testing.T) {
+	panics := true
+	it := newIdempotencyTest(t, newIdempotencyKeys(), func(w http.ResponseWriter, r *http.Request) {
+		if panics {
+			panic("handler failure")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	func() {
+		defer func() { _ = recover() }()
+		it.do("key", `{"order":"2377225624","sum":751}`)
+	}()
+
+	panics = false
+	if w := it.do("key", `{"order":"2377225624","sum":751}`); w.Code != http.StatusOK {
+		t.Errorf("status after panic = %d, want %d", w.Code, http.StatusOK)
+	}
+}
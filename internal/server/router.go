@@ -1,13 +1,23 @@
 package server
 
 import (
+	"time"
+
 	"github.com/go-chi/chi/v5"
 	chiMiddleware "github.com/go-chi/chi/v5/middleware"
 
 	"github.com/casnerano/yandex-gophermart/internal/server/handler"
 	"github.com/casnerano/yandex-gophermart/internal/server/middleware"
 	"github.com/casnerano/yandex-gophermart/internal/service/account"
+	"github.com/casnerano/yandex-gophermart/internal/service/accrual"
 	"github.com/casnerano/yandex-gophermart/internal/service/balance"
+	"github.com/casnerano/yandex-gophermart/internal/service/deadletter"
+	"github.com/casnerano/yandex-gophermart/internal/service/events"
+	"github.com/casnerano/yandex-gophermart/internal/service/health"
+	"github.com/casnerano/yandex-gophermart/internal/service/idempotency"
+	"github.com/casnerano/yandex-gophermart/internal/service/ledger"
+	"github.com/casnerano/yandex-gophermart/internal/service/metrics"
+	"github.com/casnerano/yandex-gophermart/internal/service/notification"
 	"github.com/casnerano/yandex-gophermart/internal/service/order"
 	"github.com/casnerano/yandex-gophermart/internal/service/withdraw"
 	"github.com/casnerano/yandex-gophermart/pkg/logger"
@@ -18,13 +28,31 @@ func NewRouter(
 	sOrder *order.Order,
 	sBalance *balance.Balance,
 	sWithdraw *withdraw.Withdraw,
+	sIdempotency *idempotency.Idempotency,
+	sHealth *health.Health,
+	sMetrics *metrics.Metrics,
+	sDeadLetter *deadletter.DeadLetter,
+	sLedger *ledger.Ledger,
+	sAccrualCallback *accrual.Callback,
+	sEvents *events.Hub,
+	eventsHeartbeat time.Duration,
+	sNotifier *notification.Notifier,
 	jwtSecret string,
+	adminToken string,
+	accrualCallbackSecret string,
 	logger logger.Logger,
 ) *chi.Mux {
 	accountHandler := handler.NewAccount(sAccount, logger)
 	orderHandler := handler.NewOrder(sOrder, logger)
 	balanceHandler := handler.NewBalance(sBalance, logger)
 	withdrawHandler := handler.NewWithdraw(sWithdraw, logger)
+	healthHandler := handler.NewHealth(sHealth, logger)
+	metricsHandler := handler.NewMetrics(sMetrics, logger)
+	deadLetterHandler := handler.NewDeadLetter(sDeadLetter, logger)
+	ledgerHandler := handler.NewLedger(sLedger, logger)
+	accrualCallbackHandler := handler.NewAccrualCallback(sAccrualCallback, logger)
+	eventsHandler := handler.NewEvents(sEvents, eventsHeartbeat, logger)
+	webSocketHandler := handler.NewWebSocket(sNotifier, logger)
 
 	router := chi.NewRouter()
 
@@ -42,12 +70,39 @@ func NewRouter(
 	router.Group(func(r chi.Router) {
 		r.Use(middleware.JWTAuthenticator(jwtSecret))
 		r.Post("/user/orders", orderHandler.PostUserOrder())
+		r.Post("/user/orders/batch", orderHandler.PostUserOrdersBatch())
+		r.Get("/user/orders/events", eventsHandler.GetUserEvents())
 		r.Get("/user/orders", orderHandler.GetUserOrders())
+		r.Get("/user/orders/{number}", orderHandler.GetUserOrder())
 		r.Get("/user/balance", balanceHandler.GetUserSummary())
-		r.Post("/user/balance/withdraw", withdrawHandler.PostUserBalanceWithdraw())
+		r.With(middleware.Idempotency(sIdempotency, logger)).
+			Post("/user/balance/withdraw", withdrawHandler.PostUserBalanceWithdraw())
 		r.Get("/user/withdrawals", withdrawHandler.GetUserWithdrawals())
+		r.Get("/user/ws", webSocketHandler.GetUserWS())
 	})
 
+	// Internal routes are available only with the configured accrual callback secret
+	if accrualCallbackSecret != "" {
+		router.Post("/internal/accrual/callback", accrualCallbackHandler.PostAccrualCallback())
+	}
+
+	// Admin routes are available only with the configured token.
+	// Health and metrics expose internal state and are served to administrators only.
+	if adminToken != "" {
+		router.Group(func(r chi.Router) {
+			r.Use(middleware.AdminAuthenticator(adminToken))
+			r.Get("/health", healthHandler.GetHealth())
+			r.Get("/metrics", metricsHandler.GetMetrics())
+			r.Get("/admin/dead-letters", deadLetterHandler.GetDeadLetters())
+			r.Get("/admin/dead-letters/{number}", deadLetterHandler.GetDeadLetter())
+			r.Post("/admin/dead-letters/{number}/replay", deadLetterHandler.PostDeadLetterReplay())
+			r.Delete("/admin/dead-letters/{number}", deadLetterHandler.DeleteDeadLetter())
+			r.Get("/admin/users/{uuid}/ledger", ledgerHandler.GetUserLedger())
+			r.Post("/admin/users/{uuid}/ledger/adjustments", ledgerHandler.PostLedgerAdjustment())
+			r.Post("/admin/ledger/{uuid}/reversal", ledgerHandler.PostLedgerReversal())
+		})
+	}
+
 	router.Mount("/api", router)
 
 	return router
@@ -10,9 +10,13 @@ import (
 	"github.com/casnerano/yandex-gophermart/pkg/logger"
 )
 
+// ShutdownHook завершает соединения, которые http.Server не отслеживает, например WebSocket.
+type ShutdownHook func(ctx context.Context) error
+
 type Server struct {
-	httpServer *http.Server
-	logger     logger.Logger
+	httpServer    *http.Server
+	shutdownHooks []ShutdownHook
+	logger        logger.Logger
 }
 
 func New(addr string, handler http.Handler, logger logger.Logger) *Server {
@@ -26,6 +30,18 @@ func New(addr string, handler http.Handler, logger logger.Logger) *Server {
 	return server
 }
 
+// RegisterOnShutdown регистрирует функцию, вызываемую в начале остановки сервера,
+// например для завершения долгих соединений, которые не завершаются сами.
+func (s *Server) RegisterOnShutdown(f func()) {
+	s.httpServer.RegisterOnShutdown(f)
+}
+
+// OnShutdown регистрирует хук, который выполняется после остановки приема запросов
+// в пределах того же времени ожидания, что и остановка сервера.
+func (s *Server) OnShutdown(hook ShutdownHook) {
+	s.shutdownHooks = append(s.shutdownHooks, hook)
+}
+
 func (s *Server) Run(ctx context.Context) error {
 	go func() {
 		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -53,5 +69,13 @@ func (s *Server) Run(ctx context.Context) error {
 func (s *Server) Shutdown() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	return s.httpServer.Shutdown(ctx)
+
+	err := s.httpServer.Shutdown(ctx)
+	for _, hook := range s.shutdownHooks {
+		if hookErr := hook(ctx); hookErr != nil && err == nil {
+			err = hookErr
+		}
+	}
+
+	return err
 }
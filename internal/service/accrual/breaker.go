@@ -0,0 +1,256 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/internal/service/health"
+	"github.com/casnerano/yandex-gophermart/internal/service/metrics"
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerHalfOpen
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "OPEN"
+	case BreakerHalfOpen:
+		return "HALF_OPEN"
+	default:
+		return "CLOSED"
+	}
+}
+
+var ErrCircuitOpen = errors.New("accrual system circuit is open")
+
+// Breaker предохранитель клиента системы начислений.
+//
+// После failureThreshold сбоев подряд цепь размыкается и запросы не выполняются
+// в течение coolDown. Затем пропускается единственный пробный запрос: при успехе
+// цепь замыкается, при сбое снова размыкается. Сбоем считаются ошибки сети и ответы 5xx,
+// нулевой failureThreshold отключает предохранитель.
+type Breaker struct {
+	client           Client
+	failureThreshold int
+	coolDown         time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	opens    int
+	changed  chan struct{}
+}
+
+func NewBreaker(client Client, failureThreshold int, coolDown time.Duration) *Breaker {
+	return &Breaker{
+		client:           client,
+		failureThreshold: failureThreshold,
+		coolDown:         coolDown,
+		changed:          make(chan struct{}),
+	}
+}
+
+func (b *Breaker) GetOrder(ctx context.Context, number string) (Result, error) {
+	if !b.allow() {
+		return Result{}, ErrCircuitOpen
+	}
+
+	result, err := b.client.GetOrder(ctx, number)
+	b.record(ctx, err)
+	return result, err
+}
+
+// GetOrders пакетный запрос, если его поддерживает оборачиваемый клиент.
+func (b *Breaker) GetOrders(ctx context.Context, numbers []string) (map[string]Result, error) {
+	batchClient, ok := b.client.(BatchClient)
+	if !ok {
+		return nil, ErrBatchNotSupported
+	}
+
+	if !b.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	results, err := batchClient.GetOrders(ctx, numbers)
+	b.record(ctx, err)
+	return results, err
+}
+
+// Wait блокирует вызывающего, пока цепь разомкнута или выполняется пробный запрос.
+func (b *Breaker) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		state := b.currentState()
+		if state == BreakerClosed || state == BreakerHalfOpen && !b.probing {
+			b.mu.Unlock()
+			return nil
+		}
+
+		changed := b.changed
+		delay := time.Until(b.openedAt.Add(b.coolDown))
+		b.mu.Unlock()
+
+		if err := b.waitChange(ctx, changed, state, delay); err != nil {
+			return err
+		}
+	}
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState()
+}
+
+// Check разомкнутая цепь не делает приложение неработоспособным,
+// начисления будут получены после восстановления системы начислений.
+func (b *Breaker) Check(_ context.Context) health.Report {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.currentState()
+	details := map[string]any{
+		"state":    state.String(),
+		"failures": b.failures,
+		"opens":    b.opens,
+	}
+
+	if state == BreakerClosed {
+		return health.Report{Status: health.StatusUp, Details: details}
+	}
+
+	details["opened_at"] = b.openedAt.Format(time.RFC3339)
+	return health.Report{Status: health.StatusDegraded, Details: details}
+}
+
+func (b *Breaker) Collect() []metrics.Sample {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return []metrics.Sample{
+		{
+			Name:  "accrual_circuit_breaker_state",
+			Help:  "Accrual system circuit breaker state (0 - closed, 1 - half-open, 2 - open).",
+			Type:  metrics.TypeGauge,
+			Value: float64(b.currentState()),
+		},
+		{
+			Name:  "accrual_circuit_breaker_failures",
+			Help:  "Consecutive accrual system failures.",
+			Type:  metrics.TypeGauge,
+			Value: float64(b.failures),
+		},
+		{
+			Name:  "accrual_circuit_breaker_opens_total",
+			Help:  "Number of times the accrual system circuit breaker opened.",
+			Type:  metrics.TypeCounter,
+			Value: float64(b.opens),
+		},
+	}
+}
+
+func (b *Breaker) waitChange(ctx context.Context, changed <-chan struct{}, state BreakerState, delay time.Duration) error {
+	var coolDown <-chan time.Time
+	if state == BreakerOpen {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		coolDown = timer.C
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-changed:
+	case <-coolDown:
+	}
+	return nil
+}
+
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case BreakerClosed:
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		b.notify()
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *Breaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	probe := b.probing
+	b.probing = false
+	defer b.notify()
+
+	// Отмена запроса вызывающим не говорит о состоянии системы начислений
+	if ctx.Err() != nil {
+		return
+	}
+
+	if !isFailure(err) {
+		b.failures = 0
+		b.state = BreakerClosed
+		return
+	}
+
+	b.failures++
+	if probe || b.failureThreshold > 0 && b.failures >= b.failureThreshold {
+		if b.state != BreakerOpen {
+			b.opens++
+		}
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Переводит разомкнутую цепь в полуоткрытое состояние по истечении coolDown,
+// вызывается под блокировкой mu.
+func (b *Breaker) currentState() BreakerState {
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.coolDown {
+		b.state = BreakerHalfOpen
+	}
+	return b.state
+}
+
+// Будит ожидающих изменения состояния, вызывается под блокировкой mu.
+func (b *Breaker) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// Ответы системы начислений, кроме 5xx, подтверждают ее работоспособность.
+func isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return errors.Is(err, ErrServerFailure)
+	}
+
+	return !errors.Is(err, ErrOrderNotRegistered) &&
+		!errors.Is(err, ErrRateLimited) &&
+		!errors.Is(err, ErrBatchNotSupported)
+}
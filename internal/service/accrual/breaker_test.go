@@ -0,0 +1,68 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	failure := &StatusError{StatusCode: 503}
+	client := NewFakeClient().
+		AddError("70482", failure).
+		AddError("70482", ErrOrderNotRegistered).
+		AddError("70482", failure).
+		AddError("70482", failure).
+		AddError("70482", failure).
+		AddResult(Result{Order: "70482", Status: StatusProcessed})
+
+	breaker := NewBreaker(client, 2, 50*time.Millisecond)
+	ctx := context.Background()
+
+	// Ответ без сбоя сбрасывает счетчик сбоев подряд
+	for i := 0; i < 3; i++ {
+		_, _ = breaker.GetOrder(ctx, "70482")
+	}
+	if state := breaker.State(); state != BreakerClosed {
+		t.Fatalf("State() = %s, want %s", state, BreakerClosed)
+	}
+
+	_, _ = breaker.GetOrder(ctx, "70482")
+	if state := breaker.State(); state != BreakerOpen {
+		t.Fatalf("State() = %s, want %s", state, BreakerOpen)
+	}
+
+	if _, err := breaker.GetOrder(ctx, "70482"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("GetOrder() error = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	start := time.Now()
+	if err := breaker.Wait(ctx); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Wait() returned after %v, want cool-down", elapsed)
+	}
+
+	// Неудачный пробный запрос снова размыкает цепь
+	_, _ = breaker.GetOrder(ctx, "70482")
+	if state := breaker.State(); state != BreakerOpen {
+		t.Fatalf("State() after failed probe = %s, want %s", state, BreakerOpen)
+	}
+
+	if err := breaker.Wait(ctx); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	if _, err := breaker.GetOrder(ctx, "70482"); err != nil {
+		t.Fatalf("GetOrder() error = %v", err)
+	}
+	if state := breaker.State(); state != BreakerClosed {
+		t.Errorf("State() after successful probe = %s, want %s", state, BreakerClosed)
+	}
+
+	if calls := client.Calls("70482"); calls != 6 {
+		t.Errorf("Calls() = %d, want 6", calls)
+	}
+}
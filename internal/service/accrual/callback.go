@@ -0,0 +1,116 @@
+package accrual
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/internal/service/order"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid accrual callback signature")
+	ErrExpiredCallback  = errors.New("accrual callback timestamp out of tolerance")
+)
+
+// Callback прием результатов расчета, которые система начислений отправляет сама.
+//
+// Запрос подписывается HMAC-SHA256 общего секрета от строки "<timestamp>.<body>",
+// где timestamp - время отправки в секундах Unix. Запросы со временем отправки
+// за пределами tolerance отклоняются, чтобы перехваченный запрос нельзя было повторить.
+// Опрос системы начислений остается запасным способом получения результатов.
+type Callback struct {
+	observer     *Observer
+	orderService *order.Order
+	secret       []byte
+	tolerance    time.Duration
+	logger       logger.Logger
+}
+
+func NewCallback(
+	observer *Observer,
+	orderService *order.Order,
+	secret string,
+	tolerance time.Duration,
+	logger logger.Logger,
+) *Callback {
+	return &Callback{
+		observer:     observer,
+		orderService: orderService,
+		secret:       []byte(secret),
+		tolerance:    tolerance,
+		logger:       logger,
+	}
+}
+
+// SignCallback подпись тела запроса, отправленного в момент timestamp.
+func SignCallback(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify проверяет подпись и время отправки запроса.
+func (c *Callback) Verify(body []byte, timestamp, signature string) error {
+	if len(c.secret) == 0 {
+		return ErrInvalidSignature
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	sentAt := time.Unix(seconds, 0)
+	expected := SignCallback(string(c.secret), sentAt, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	if c.tolerance > 0 {
+		if age := time.Since(sentAt); age > c.tolerance || age < -c.tolerance {
+			return ErrExpiredCallback
+		}
+	}
+
+	return nil
+}
+
+// Apply обновляет заказ по результату расчета. Повторная доставка результата
+// с уже установленным статусом или для заказа в финальном статусе не изменяет его и возвращает false.
+func (c *Callback) Apply(ctx context.Context, result Result) (bool, error) {
+	status, err := toOrderStatus(result.Status)
+	if err != nil {
+		return false, err
+	}
+
+	found, err := c.orderService.FindByNumber(ctx, result.Order)
+	if err != nil {
+		return false, err
+	}
+
+	if found.Status == status {
+		return false, nil
+	}
+
+	err = c.observer.UpdateOrder(ctx, result, model.OrderStatusSourceCallback)
+	if errors.Is(err, repository.ErrOrderStatusTransition) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	c.logger.Info(fmt.Sprintf("Order `\"%s\" updated by accrual callback to %s", result.Order, status))
+	return true, nil
+}
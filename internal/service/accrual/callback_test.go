@@ -0,0 +1,38 @@
+package accrual
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+func TestCallback_Verify(t *testing.T) {
+	callback := NewCallback(nil, nil, "secret", time.Minute, logger.New())
+	body := []byte(`{"order":"70482","status":"PROCESSED","accrual":500}`)
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		body      []byte
+		timestamp time.Time
+		secret    string
+		want      error
+	}{
+		{"valid", body, now, "secret", nil},
+		{"tampered body", []byte(`{"order":"70482","status":"PROCESSED","accrual":5000}`), now, "secret", ErrInvalidSignature},
+		{"another secret", body, now, "another", ErrInvalidSignature},
+		{"expired", body, now.Add(-2 * time.Minute), "secret", ErrExpiredCallback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signature := SignCallback(tt.secret, tt.timestamp, body)
+			err := callback.Verify(tt.body, strconv.FormatInt(tt.timestamp.Unix(), 10), signature)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
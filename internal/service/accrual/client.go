@@ -0,0 +1,186 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
+
+const (
+	StatusRegistered = "REGISTERED"
+	StatusInvalid    = "INVALID"
+	StatusProcessing = "PROCESSING"
+	StatusProcessed  = "PROCESSED"
+)
+
+var (
+	ErrOrderNotRegistered = errors.New("order not registered in accrual system")
+	ErrRateLimited        = errors.New("accrual system rate limit exceeded")
+	ErrServerFailure      = errors.New("accrual system failure")
+	ErrBatchNotSupported  = errors.New("accrual system does not support batch lookups")
+)
+
+// Result расчет начисления по заказу в системе начислений.
+// ResponseCode код ответа системы начислений, с которым получен расчет.
+type Result struct {
+	Order        string      `json:"order"`
+	Status       string      `json:"status"`
+	Accrual      money.Money `json:"accrual,omitempty"`
+	ResponseCode int         `json:"-"`
+}
+
+// RateLimitError превышение лимита запросов. RetryAfter равен нулю,
+// если система начислений не указала время ожидания.
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s: retry after %s", ErrRateLimited, e.RetryAfter)
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// StatusError неожиданный ответ системы начислений, ответы 5xx считаются сбоем системы.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected accrual system response status %d", e.StatusCode)
+}
+
+func (e *StatusError) Is(target error) bool {
+	return target == ErrServerFailure && e.StatusCode >= http.StatusInternalServerError
+}
+
+// Client клиент системы начислений.
+type Client interface {
+	GetOrder(ctx context.Context, number string) (Result, error)
+}
+
+// BatchClient клиент системы начислений, запрашивающий несколько заказов одним запросом.
+// Незарегистрированные в системе начислений заказы в результате отсутствуют.
+type BatchClient interface {
+	GetOrders(ctx context.Context, numbers []string) (map[string]Result, error)
+}
+
+type batchRequest struct {
+	Orders []string `json:"orders"`
+}
+
+// HTTPClient клиент системы начислений поверх HTTP с общим пулом соединений.
+type HTTPClient struct {
+	client *resty.Client
+}
+
+// NewHTTPClient создает клиент системы начислений, доступной по адресу address.
+// Адрес задается один раз при создании, поэтому клиент безопасен для конкурентного использования.
+func NewHTTPClient(address string, timeout time.Duration) *HTTPClient {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: timeout,
+	}
+
+	client := resty.New().
+		SetTransport(transport).
+		SetTimeout(timeout).
+		SetBaseURL(strings.TrimRight(address, "/") + "/api")
+
+	return &HTTPClient{client: client}
+}
+
+func (c *HTTPClient) GetOrder(ctx context.Context, number string) (Result, error) {
+	result := Result{}
+	response, err := c.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		SetPathParam("number", number).
+		Get("/orders/{number}")
+	if err != nil {
+		return Result{}, err
+	}
+
+	switch response.StatusCode() {
+	case http.StatusOK:
+		result.ResponseCode = response.StatusCode()
+		return result, nil
+	case http.StatusNoContent:
+		return Result{}, ErrOrderNotRegistered
+	case http.StatusTooManyRequests:
+		return Result{}, &RateLimitError{
+			RetryAfter: parseRetryAfter(response.Header().Get("Retry-After")),
+			Message:    string(response.Body()),
+		}
+	default:
+		return Result{}, &StatusError{StatusCode: response.StatusCode()}
+	}
+}
+
+// GetOrders запрашивает заказы через POST /api/orders/batch. Отсутствие этого метода
+// у системы начислений означает, что пакетные запросы не поддерживаются.
+func (c *HTTPClient) GetOrders(ctx context.Context, numbers []string) (map[string]Result, error) {
+	var results []Result
+	response, err := c.client.R().
+		SetContext(ctx).
+		SetBody(batchRequest{Orders: numbers}).
+		SetResult(&results).
+		Post("/orders/batch")
+	if err != nil {
+		return nil, err
+	}
+
+	switch response.StatusCode() {
+	case http.StatusOK:
+		found := make(map[string]Result, len(results))
+		for _, result := range results {
+			result.ResponseCode = response.StatusCode()
+			found[result.Order] = result
+		}
+		return found, nil
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, ErrBatchNotSupported
+	case http.StatusTooManyRequests:
+		return nil, &RateLimitError{
+			RetryAfter: parseRetryAfter(response.Header().Get("Retry-After")),
+			Message:    string(response.Body()),
+		}
+	default:
+		return nil, &StatusError{StatusCode: response.StatusCode()}
+	}
+}
+
+// Заголовок Retry-After содержит количество секунд или дату.
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+
+	return 0
+}
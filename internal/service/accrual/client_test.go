@@ -0,0 +1,99 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
+
+func TestHTTPClient_GetOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/orders/70482":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"order":"70482","status":"PROCESSED","accrual":729.98}`)
+		case "/api/orders/18":
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/orders/26":
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, "No more than 10 requests per minute allowed")
+		case "/api/orders/34":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(server.URL, time.Second)
+
+	result, err := client.GetOrder(context.Background(), "70482")
+	if err != nil {
+		t.Fatalf("GetOrder() error = %v", err)
+	}
+	want := Result{Order: "70482", Status: StatusProcessed, Accrual: money.Money(72998), ResponseCode: http.StatusOK}
+	if result != want {
+		t.Errorf("GetOrder() = %+v, want %+v", result, want)
+	}
+
+	if _, err = client.GetOrder(context.Background(), "18"); !errors.Is(err, ErrOrderNotRegistered) {
+		t.Errorf("GetOrder() error = %v, want %v", err, ErrOrderNotRegistered)
+	}
+
+	_, err = client.GetOrder(context.Background(), "26")
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("GetOrder() error = %v, want %v", err, ErrRateLimited)
+	}
+	if rateLimitErr.RetryAfter != time.Minute || rateLimitErr.Message != "No more than 10 requests per minute allowed" {
+		t.Errorf("GetOrder() rate limit = %+v", rateLimitErr)
+	}
+
+	if _, err = client.GetOrder(context.Background(), "34"); !errors.Is(err, ErrServerFailure) {
+		t.Errorf("GetOrder() error = %v, want %v", err, ErrServerFailure)
+	}
+
+	_, err = client.GetOrder(context.Background(), "42")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || errors.Is(err, ErrServerFailure) {
+		t.Errorf("GetOrder() error = %v, want status error", err)
+	}
+}
+
+func TestHTTPClient_GetOrders(t *testing.T) {
+	status := http.StatusTooManyRequests
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "60")
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(server.URL, time.Second)
+
+	_, err := client.GetOrders(context.Background(), []string{"70482", "18"})
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) || rateLimitErr.RetryAfter != time.Minute {
+		t.Errorf("GetOrders() error = %v, want rate limit with retry after %s", err, time.Minute)
+	}
+
+	status = http.StatusNotImplemented
+	if _, err = client.GetOrders(context.Background(), []string{"70482"}); !errors.Is(err, ErrBatchNotSupported) {
+		t.Errorf("GetOrders() error = %v, want %v", err, ErrBatchNotSupported)
+	}
+
+	status = http.StatusBadRequest
+	_, err = client.GetOrders(context.Background(), []string{"70482"})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || errors.Is(err, ErrServerFailure) {
+		t.Errorf("GetOrders() error = %v, want status error", err)
+	}
+}
@@ -0,0 +1,114 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// FakeClient клиент системы начислений для тестов. Ответы задаются по номеру заказа
+// и выдаются по очереди, последний ответ повторяется. Для незаданных заказов
+// возвращается ErrOrderNotRegistered.
+type FakeClient struct {
+	mu         sync.Mutex
+	responses  map[string][]fakeResponse
+	calls      map[string]int
+	batchCalls int
+	batchErr   error
+}
+
+type fakeResponse struct {
+	result Result
+	err    error
+}
+
+func NewFakeClient() *FakeClient {
+	return &FakeClient{
+		responses: make(map[string][]fakeResponse),
+		calls:     make(map[string]int),
+	}
+}
+
+// AddResult добавляет очередной ответ с расчетом начисления.
+func (f *FakeClient) AddResult(result Result) *FakeClient {
+	return f.add(result.Order, fakeResponse{result: result})
+}
+
+// AddError добавляет очередной ответ с ошибкой для заказа.
+func (f *FakeClient) AddError(number string, err error) *FakeClient {
+	return f.add(number, fakeResponse{err: err})
+}
+
+// Calls количество запросов по заказу.
+func (f *FakeClient) Calls(number string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[number]
+}
+
+// SetBatchError задает ошибку пакетных запросов, например ErrBatchNotSupported.
+func (f *FakeClient) SetBatchError(err error) *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batchErr = err
+	return f
+}
+
+// BatchCalls количество пакетных запросов.
+func (f *FakeClient) BatchCalls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.batchCalls
+}
+
+func (f *FakeClient) GetOrder(_ context.Context, number string) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.next(number)
+}
+
+// GetOrders выдает очередные ответы по каждому заказу. Ошибка ответа любого
+// из заказов становится ошибкой всего запроса.
+func (f *FakeClient) GetOrders(_ context.Context, numbers []string) (map[string]Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.batchCalls++
+	if f.batchErr != nil {
+		return nil, f.batchErr
+	}
+
+	results := make(map[string]Result, len(numbers))
+	for _, number := range numbers {
+		result, err := f.next(number)
+		if errors.Is(err, ErrOrderNotRegistered) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results[number] = result
+	}
+	return results, nil
+}
+
+func (f *FakeClient) next(number string) (Result, error) {
+	f.calls[number]++
+	responses := f.responses[number]
+	if len(responses) == 0 {
+		return Result{}, ErrOrderNotRegistered
+	}
+
+	response := responses[0]
+	if len(responses) > 1 {
+		f.responses[number] = responses[1:]
+	}
+	return response.result, response.err
+}
+
+func (f *FakeClient) add(number string, response fakeResponse) *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[number] = append(f.responses[number], response)
+	return f
+}
@@ -0,0 +1,101 @@
+package accrual
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var limitMessageRegexp = regexp.MustCompile(`No more than (\d+) requests per minute allowed`)
+
+// RateLimiter общий для всех обработчиков ограничитель запросов к системе начислений.
+// Запросы равномерно распределяются во времени, а ответ 429 приостанавливает
+// все обработчики на время, указанное в Retry-After.
+type RateLimiter struct {
+	mu          sync.Mutex
+	interval    time.Duration
+	next        time.Time
+	pausedUntil time.Time
+}
+
+// NewRateLimiter создает ограничитель на requestsPerMinute запросов в минуту,
+// нулевое значение снимает ограничение до получения лимита от системы начислений.
+func NewRateLimiter(requestsPerMinute int) *RateLimiter {
+	limiter := &RateLimiter{}
+	limiter.SetLimit(requestsPerMinute)
+	return limiter
+}
+
+// Wait блокирует вызывающего до момента, когда разрешен очередной запрос.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	start := now
+	if l.next.After(start) {
+		start = l.next
+	}
+	if l.pausedUntil.After(start) {
+		start = l.pausedUntil
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := start.Sub(now)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Pause приостанавливает все запросы на duration.
+func (l *RateLimiter) Pause(duration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := time.Now().Add(duration)
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+	if l.next.Before(l.pausedUntil) {
+		l.next = l.pausedUntil
+	}
+}
+
+func (l *RateLimiter) SetLimit(requestsPerMinute int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if requestsPerMinute <= 0 {
+		l.interval = 0
+		return
+	}
+	l.interval = time.Minute / time.Duration(requestsPerMinute)
+}
+
+// Learn устанавливает лимит из тела ответа 429 вида
+// "No more than N requests per minute allowed". Возвращает false, если лимит не найден.
+func (l *RateLimiter) Learn(body []byte) bool {
+	matches := limitMessageRegexp.FindSubmatch(body)
+	if matches == nil {
+		return false
+	}
+
+	requestsPerMinute, err := strconv.Atoi(string(matches[1]))
+	if err != nil || requestsPerMinute <= 0 {
+		return false
+	}
+
+	l.SetLimit(requestsPerMinute)
+	return true
+}
@@ -0,0 +1,51 @@
+package accrual
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Learn(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		want         bool
+		wantInterval time.Duration
+	}{
+		{"limit message", "No more than 60 requests per minute allowed", true, time.Second},
+		{"unknown message", "Too Many Requests", false, 0},
+		{"zero limit", "No more than 0 requests per minute allowed", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter(0)
+			if got := limiter.Learn([]byte(tt.body)); got != tt.want {
+				t.Errorf("Learn() = %v, want %v", got, tt.want)
+			}
+			if limiter.interval != tt.wantInterval {
+				t.Errorf("interval = %v, want %v", limiter.interval, tt.wantInterval)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_Pause(t *testing.T) {
+	limiter := NewRateLimiter(0)
+	limiter.Pause(50 * time.Millisecond)
+
+	start := time.Now()
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Wait() returned after %v, want pause to be honored", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter.Pause(time.Minute)
+	if err := limiter.Wait(ctx); err == nil {
+		t.Error("Wait() with canceled context returned nil error")
+	}
+}
@@ -4,112 +4,336 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/http"
 	"strconv"
+	"sync/atomic"
 	"time"
 
-	"github.com/go-resty/resty/v2"
-	amqp "github.com/rabbitmq/amqp091-go"
-
 	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
 	"github.com/casnerano/yandex-gophermart/internal/service/order"
+	"github.com/casnerano/yandex-gophermart/internal/service/queue"
 	"github.com/casnerano/yandex-gophermart/pkg/logger"
 )
 
-type Data struct {
-	Order   string  `json:"order"`
-	Status  string  `json:"status"`
-	Accrual float64 `json:"accrual,omitempty"`
+// Polling ограничения повторного опроса заказов в не финальных статусах.
+type Polling struct {
+	MaxAttempts int
+	MaxAge      time.Duration
+	MaxDelay    time.Duration
+}
+
+var (
+	ErrPollingExhausted = errors.New("order polling attempts exhausted")
+	ErrUnknownStatus    = errors.New("unknown order status")
+)
+
+type waiter interface {
+	Wait(ctx context.Context) error
 }
 
+const maxBackoffDoublings = 16
+
+// Состояние опроса заказа передается в заголовках отложенного сообщения, поэтому
+// не теряется, если следующую попытку обработает другой экземпляр приложения.
+const (
+	headerPollAttempt   = "poll-attempt"
+	headerPollStartedAt = "poll-started-at"
+)
+
 type Observer struct {
-	client       *resty.Client
-	accrualURL   string
-	poolInterval int
-	orderService *order.Order
-	logger       logger.Logger
+	client            Client
+	scheduler         queue.DelayedPublisher
+	poolInterval      int
+	polling           Polling
+	limiter           *RateLimiter
+	defaultRetryAfter time.Duration
+	orderService      *order.Order
+	batchUnsupported  atomic.Bool
+	logger            logger.Logger
 }
 
 func NewObserver(
-	client *resty.Client,
-	accrualURL string,
+	client Client,
+	scheduler queue.DelayedPublisher,
 	poolInterval int,
+	polling Polling,
+	limiter *RateLimiter,
+	defaultRetryAfter time.Duration,
 	orderService *order.Order,
 	logger logger.Logger,
 ) *Observer {
 	return &Observer{
-		client:       client,
-		accrualURL:   accrualURL,
-		poolInterval: poolInterval,
-		orderService: orderService,
-		logger:       logger,
+		client:            client,
+		scheduler:         scheduler,
+		poolInterval:      poolInterval,
+		polling:           polling,
+		limiter:           limiter,
+		defaultRetryAfter: defaultRetryAfter,
+		orderService:      orderService,
+		logger:            logger,
+	}
+}
+
+func (o *Observer) Observe(ctx context.Context, message queue.Message) error {
+	if err := o.limiter.Wait(ctx); err != nil {
+		_ = message.Nack(true)
+		return err
+	}
+
+	result, err := o.client.GetOrder(ctx, string(message.Body()))
+	return o.handle(ctx, message, result, err)
+}
+
+// ObserveBatch запрашивает заказы одним запросом, если система начислений
+// поддерживает пакетные запросы, иначе по одному.
+func (o *Observer) ObserveBatch(ctx context.Context, messages []queue.Message) error {
+	batchClient, ok := o.client.(BatchClient)
+	if len(messages) == 1 || !ok || o.batchUnsupported.Load() {
+		return o.observeEach(ctx, messages)
+	}
+
+	if err := o.limiter.Wait(ctx); err != nil {
+		for _, message := range messages {
+			_ = message.Nack(true)
+		}
+		return err
+	}
+
+	numbers := make([]string, 0, len(messages))
+	for _, message := range messages {
+		numbers = append(numbers, string(message.Body()))
+	}
+
+	results, err := batchClient.GetOrders(ctx, numbers)
+	if errors.Is(err, ErrBatchNotSupported) {
+		o.logger.Notice("Accrual system does not support batch lookups, falling back to single lookups")
+		o.batchUnsupported.Store(true)
+		return o.observeEach(ctx, messages)
+	}
+
+	// Отклоненный пакетный запрос не должен переносить все заказы пакета в недоставленные:
+	// заказы запрашиваются по одному и ошибка учитывается только для заказа, который ее вызвал.
+	// Превышение лимита запросов обрабатывается для каждого заказа как при одиночном запросе.
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && !errors.Is(err, ErrServerFailure) {
+		o.logger.Warning("Accrual system rejected batch lookup, falling back to single lookups", err)
+		return o.observeEach(ctx, messages)
 	}
+
+	var firstErr error
+	for _, message := range messages {
+		result, found := results[string(message.Body())]
+		resultErr := err
+		if err == nil && !found {
+			resultErr = ErrOrderNotRegistered
+		}
+
+		if handleErr := o.handle(ctx, message, result, resultErr); handleErr != nil && firstErr == nil {
+			firstErr = handleErr
+		}
+	}
+
+	return firstErr
 }
 
-func (o *Observer) Observe(ctx context.Context, delivery amqp.Delivery) error {
-	for {
-		data := Data{}
-		response, err := o.client.SetBaseURL(o.accrualURL + "/api").
-			R().SetResult(&data).Get("/orders/" + string(delivery.Body))
+// Ошибки обработки каждого заказа уже учтены в его сообщении, возвращается первая из них.
+func (o *Observer) observeEach(ctx context.Context, messages []queue.Message) error {
+	var firstErr error
+	for _, message := range messages {
+		if err := o.Observe(ctx, message); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Обрабатывает ответ системы начислений по заказу из сообщения.
+func (o *Observer) handle(ctx context.Context, message queue.Message, result Result, err error) error {
+	number := string(message.Body())
+
+	var (
+		rateLimitErr *RateLimitError
+		statusErr    *StatusError
+	)
+	switch {
+	case err == nil:
+		err = o.UpdateOrder(ctx, result, model.OrderStatusSourcePolling)
+		if errors.Is(err, repository.ErrOrderStatusTransition) {
+			// Заказ уже в финальном статусе, результат доставлен повторно
+			_ = message.Ack()
+			o.logger.Info(fmt.Sprintf("Order `\"%s\" already processed, skipped accrual result", number), err)
+			return nil
+		}
 		if err != nil {
-			o.logger.Error(fmt.Sprintf("Failed order `\"%s\" observe in accrual system", delivery.Body), err)
-			_ = delivery.Nack(false, true)
+			o.logger.Error(fmt.Sprintf("Failed processing accrual for order `\"%s\" in accrual system", number), err)
+			_ = queue.NackWithError(message, true, err)
 			return err
 		}
 
-		switch response.StatusCode() {
-		case http.StatusOK:
-			err = o.UpdateOrder(ctx, data)
-			if err != nil {
-				o.logger.Error(fmt.Sprintf("Failed processing accrual for order `\"%s\" in accrual system", delivery.Body), err)
-				_ = delivery.Nack(false, true)
-			}
-			_ = delivery.Ack(false)
-			o.logger.Info(fmt.Sprintf("Successfully processing accrua for order `\"%s\"", delivery.Body))
+		if isFinalStatus(result.Status) {
+			_ = message.Ack()
+			o.logger.Info(fmt.Sprintf("Successfully processing accrua for order `\"%s\"", number))
 			return nil
-		case http.StatusNoContent:
-			o.logger.Error(fmt.Sprintf("Not registered order `\"%s\" in accrual system", delivery.Body), err)
-			_ = delivery.Ack(false)
-			return nil
-		case http.StatusTooManyRequests:
-			o.logger.Error(fmt.Sprintf("Too Many Requests for order `\"%s\" in accrual system", delivery.Body), err)
-
-			retryAfter := response.Header().Get("Retry-After")
-			if retryAfter == "" {
-				_ = delivery.Nack(false, true)
-			}
-
-			var retryAfterValue int
-			retryAfterValue, err = strconv.Atoi(retryAfter)
-			if err != nil {
-				retryAfterValue = 1
-			}
-
-			select {
-			case <-ctx.Done():
-				_ = delivery.Nack(false, true)
-			case <-time.After(time.Second * time.Duration(retryAfterValue)):
-			}
-		default:
-			o.logger.Error(fmt.Sprintf("Unknown response status for order `\"%s\" in accrual system", delivery.Body), response.Error())
-			return errors.New("unknown response status")
 		}
+
+		attempt, startedAt := pollState(message.Headers())
+		attempt++
+		if o.pollingExhausted(attempt, startedAt) {
+			o.logger.Warning(fmt.Sprintf("Order `\"%s\" still %s after %d attempts", number, result.Status, attempt))
+			// Заказ будет повторно поставлен в очередь сверкой зависших заказов
+			_ = queue.NackWithError(message, false, ErrPollingExhausted)
+			return ErrPollingExhausted
+		}
+
+		return o.reschedule(ctx, message, pollHeaders(attempt, startedAt), o.backoff(attempt))
+	case errors.Is(err, ErrCircuitOpen):
+		// Обработчик дождется замыкания цепи перед получением следующего задания, см. Ready
+		_ = message.Nack(true)
+		return err
+	case errors.Is(err, ErrOrderNotRegistered):
+		o.logger.Error(fmt.Sprintf("Not registered order `\"%s\" in accrual system", number), err)
+		_ = message.Ack()
+		return nil
+	case errors.As(err, &rateLimitErr):
+		o.logger.Error(fmt.Sprintf("Too Many Requests for order `\"%s\" in accrual system", number), err)
+
+		retryAfter := rateLimitErr.RetryAfter
+		if retryAfter <= 0 {
+			retryAfter = o.defaultRetryAfter
+		}
+
+		// Пауза распространяется на все обработчики, а заказ откладывается до ее окончания
+		o.limiter.Pause(retryAfter)
+		if o.limiter.Learn([]byte(rateLimitErr.Message)) {
+			o.logger.Info("Accrual system rate limit updated", rateLimitErr.Message)
+		}
+
+		// Ожидание лимита запросов не считается попыткой опроса
+		return o.reschedule(ctx, message, message.Headers(), retryAfter)
+	case errors.As(err, &statusErr) && !errors.Is(err, ErrServerFailure):
+		o.logger.Error(fmt.Sprintf("Unknown response status for order `\"%s\" in accrual system", number), err)
+		// Заказ будет повторно поставлен в очередь сверкой зависших заказов
+		_ = queue.NackWithError(message, false, err)
+		return err
+	default:
+		// Сбой сети или системы начислений, заказ будет запрошен повторно
+		o.logger.Error(fmt.Sprintf("Failed order `\"%s\" observe in accrual system", number), err)
+		_ = queue.NackWithError(message, true, err)
+		return err
 	}
 }
 
-func (o *Observer) UpdateOrder(ctx context.Context, data Data) error {
-	var status model.OrderStatus
-	switch data.Status {
-	case "INVALID":
-		status = model.OrderStatusInvalid
-	case "PROCESSING":
-		status = model.OrderStatusProcessing
-	case "PROCESSED":
-		status = model.OrderStatusProcessed
-	default:
-		return errors.New("unknown order status")
+// Ready ожидает готовности клиента системы начислений выполнять запросы,
+// например замыкания цепи предохранителя.
+func (o *Observer) Ready(ctx context.Context) error {
+	if waiter, ok := o.client.(waiter); ok {
+		return waiter.Wait(ctx)
 	}
+	return nil
+}
 
-	_, err := o.orderService.AccrueByNumber(ctx, data.Order, status, data.Accrual)
+// Подтверждает сообщение и публикует заказ повторно с задержкой,
+// не удерживая обработчик и неподтвержденное сообщение на время ожидания.
+func (o *Observer) reschedule(ctx context.Context, message queue.Message, headers queue.Headers, delay time.Duration) error {
+	if err := o.scheduler.PublishDelayed(ctx, message.Body(), headers, delay); err != nil {
+		o.logger.Error(fmt.Sprintf("Failed reschedule order `\"%s\"", message.Body()), err)
+		_ = message.Nack(true)
+		return err
+	}
+	return message.Ack()
+}
+
+func (o *Observer) UpdateOrder(ctx context.Context, result Result, source model.OrderStatusSource) error {
+	status, err := toOrderStatus(result.Status)
+	if err != nil {
+		return err
+	}
+
+	// Баллы зачисляются только при переходе заказа в финальный статус
+	accrual := result.Accrual
+	if status != model.OrderStatusProcessed {
+		accrual = 0
+	}
+
+	_, err = o.orderService.AccrueByNumber(ctx, result.Order, &model.OrderStatusChange{
+		Status:       status,
+		Accrual:      accrual,
+		Source:       source,
+		ResponseCode: result.ResponseCode,
+	})
 	return err
 }
+
+// Задержка перед очередным опросом заказа: poolInterval * 2^(attempt-1), но не более MaxDelay.
+func (o *Observer) backoff(attempt int) time.Duration {
+	base := time.Duration(o.poolInterval) * time.Second
+	if base <= 0 {
+		base = time.Second
+	}
+
+	delay := base
+	for i := 1; i < attempt && i < maxBackoffDoublings; i++ {
+		delay *= 2
+		if o.polling.MaxDelay > 0 && delay >= o.polling.MaxDelay {
+			return o.polling.MaxDelay
+		}
+	}
+	return delay
+}
+
+// MaxBackoff наибольшая задержка перед повторным опросом заказа.
+func (o *Observer) MaxBackoff() time.Duration {
+	attempt := maxBackoffDoublings
+	if o.polling.MaxAttempts > 0 && o.polling.MaxAttempts-1 < attempt {
+		attempt = o.polling.MaxAttempts - 1
+	}
+	return o.backoff(attempt)
+}
+
+func (o *Observer) pollingExhausted(attempts int, startedAt time.Time) bool {
+	if o.polling.MaxAttempts > 0 && attempts >= o.polling.MaxAttempts {
+		return true
+	}
+	return o.polling.MaxAge > 0 && time.Since(startedAt) >= o.polling.MaxAge
+}
+
+// Статусы REGISTERED и PROCESSING системы начислений для пользователя не различаются.
+func toOrderStatus(status string) (model.OrderStatus, error) {
+	switch status {
+	case StatusInvalid:
+		return model.OrderStatusInvalid, nil
+	case StatusRegistered, StatusProcessing:
+		return model.OrderStatusProcessing, nil
+	case StatusProcessed:
+		return model.OrderStatusProcessed, nil
+	default:
+		return "", ErrUnknownStatus
+	}
+}
+
+func isFinalStatus(status string) bool {
+	return status == StatusProcessed || status == StatusInvalid
+}
+
+// Количество выполненных попыток опроса заказа и время первой из них.
+// Для сообщения без состояния опроса, например первой публикации заказа, отсчет начинается сейчас.
+func pollState(headers queue.Headers) (int, time.Time) {
+	attempt, err := strconv.Atoi(headers[headerPollAttempt])
+	if err != nil || attempt < 0 {
+		return 0, time.Now()
+	}
+
+	startedAt, err := time.Parse(time.RFC3339Nano, headers[headerPollStartedAt])
+	if err != nil {
+		return attempt, time.Now()
+	}
+
+	return attempt, startedAt
+}
+
+func pollHeaders(attempt int, startedAt time.Time) queue.Headers {
+	return queue.Headers{
+		headerPollAttempt:   strconv.Itoa(attempt),
+		headerPollStartedAt: startedAt.Format(time.RFC3339Nano),
+	}
+}
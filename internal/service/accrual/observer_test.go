@@ -0,0 +1,295 @@
+package accrual
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/internal/service/order"
+	"github.com/casnerano/yandex-gophermart/internal/service/queue"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
+
+type accruals struct {
+	repository.Order
+	mu       sync.Mutex
+	statuses map[string]model.OrderStatus
+	accruals map[string]money.Money
+}
+
+func (a *accruals) AccrueByNumber(_ context.Context, number string, change *model.OrderStatusChange) (*model.Order, error) {
+	status, accrual := change.Status, change.Accrual
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	current, found := a.statuses[number]
+	if !found {
+		current = model.OrderStatusNew
+	}
+	if !current.CanTransitionTo(status) {
+		return nil, &repository.OrderTransitionError{Number: number, From: current, To: status}
+	}
+
+	a.statuses[number] = status
+	a.accruals[number] += accrual
+	return &model.Order{Number: number, Status: status, Accrual: accrual}, nil
+}
+
+func TestObserver_Observe(t *testing.T) {
+	repo := &accruals{statuses: map[string]model.OrderStatus{}, accruals: map[string]money.Money{}}
+	client := NewFakeClient().
+		AddResult(Result{Order: "70482", Status: StatusRegistered}).
+		AddError("70482", &RateLimitError{RetryAfter: 10 * time.Millisecond}).
+		AddResult(Result{Order: "70482", Status: StatusProcessed, Accrual: money.Money(50000)})
+
+	q := queue.NewMemory(10, 3)
+	defer q.Close()
+
+	observer := NewObserver(
+		client,
+		q,
+		0,
+		Polling{MaxAttempts: 5},
+		NewRateLimiter(0),
+		time.Millisecond,
+		order.New(repo),
+		logger.New(),
+	)
+
+	messages, err := q.Consume()
+	if err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+
+	if err = q.Publish(context.Background(), []byte("70482")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case message := <-messages:
+			_ = observer.Observe(context.Background(), message)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("message %d not received", i+1)
+		}
+	}
+
+	if got := client.Calls("70482"); got != 3 {
+		t.Errorf("Calls() = %d, want 3", got)
+	}
+
+	if repo.statuses["70482"] != model.OrderStatusProcessed || repo.accruals["70482"] != money.Money(50000) {
+		t.Errorf("order = %s %s, want PROCESSED 500", repo.statuses["70482"], repo.accruals["70482"])
+	}
+}
+
+func TestObserver_ObserveBatch(t *testing.T) {
+	tests := []struct {
+		name           string
+		batchErr       error
+		wantBatchCalls int
+		wantCalls      int
+	}{
+		{"batch", nil, 1, 1},
+		{"fallback to single lookups", ErrBatchNotSupported, 1, 1},
+		{"fallback on rejected batch", &StatusError{StatusCode: http.StatusBadRequest}, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &accruals{statuses: map[string]model.OrderStatus{}, accruals: map[string]money.Money{}}
+			client := NewFakeClient().
+				AddResult(Result{Order: "70482", Status: StatusProcessed, Accrual: money.Money(50000)}).
+				AddResult(Result{Order: "18", Status: StatusInvalid}).
+				SetBatchError(tt.batchErr)
+
+			q := queue.NewMemory(10, 3)
+			defer q.Close()
+
+			observer := NewObserver(client, q, 0, Polling{MaxAttempts: 5}, NewRateLimiter(0), time.Millisecond, order.New(repo), logger.New())
+
+			messages, err := q.Consume()
+			if err != nil {
+				t.Fatalf("Consume() error = %v", err)
+			}
+
+			var batch []queue.Message
+			for _, number := range []string{"70482", "18", "26"} {
+				if err = q.Publish(context.Background(), []byte(number)); err != nil {
+					t.Fatalf("Publish() error = %v", err)
+				}
+				batch = append(batch, <-messages)
+			}
+
+			if err = observer.ObserveBatch(context.Background(), batch); err != nil {
+				t.Fatalf("ObserveBatch() error = %v", err)
+			}
+
+			if got := client.BatchCalls(); got != tt.wantBatchCalls {
+				t.Errorf("BatchCalls() = %d, want %d", got, tt.wantBatchCalls)
+			}
+			if got := client.Calls("70482"); got != tt.wantCalls {
+				t.Errorf("Calls() = %d, want %d", got, tt.wantCalls)
+			}
+			if repo.statuses["70482"] != model.OrderStatusProcessed || repo.statuses["18"] != model.OrderStatusInvalid {
+				t.Errorf("statuses = %v, want 70482 PROCESSED and 18 INVALID", repo.statuses)
+			}
+			if _, found := repo.statuses["26"]; found {
+				t.Errorf("unregistered order 26 updated")
+			}
+		})
+	}
+}
+
+func TestObserver_ObserveBatchRateLimited(t *testing.T) {
+	repo := &accruals{statuses: map[string]model.OrderStatus{}, accruals: map[string]money.Money{}}
+	client := NewFakeClient().SetBatchError(&RateLimitError{RetryAfter: 50 * time.Millisecond})
+
+	q := queue.NewMemory(10, 3)
+	defer q.Close()
+
+	observer := NewObserver(client, q, 0, Polling{MaxAttempts: 5}, NewRateLimiter(0), time.Second, order.New(repo), logger.New())
+
+	messages, err := q.Consume()
+	if err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+
+	var batch []queue.Message
+	for _, number := range []string{"70482", "18"} {
+		if err = q.Publish(context.Background(), []byte(number)); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		batch = append(batch, <-messages)
+	}
+
+	if err = observer.ObserveBatch(context.Background(), batch); err != nil {
+		t.Fatalf("ObserveBatch() error = %v", err)
+	}
+
+	// Заказы откладываются на время из Retry-After, а не переносятся в недоставленные
+	rescheduled := map[string]bool{}
+	for len(rescheduled) < 2 {
+		select {
+		case message := <-messages:
+			rescheduled[string(message.Body())] = true
+		case <-time.After(time.Second):
+			t.Fatalf("rescheduled orders = %v, want 70482 and 18", rescheduled)
+		}
+	}
+
+	if deadLetters, _ := q.DeadLetters(context.Background(), 10); len(deadLetters) != 0 {
+		t.Errorf("DeadLetters() = %v, want none", deadLetters)
+	}
+	if got := client.Calls("70482"); got != 0 {
+		t.Errorf("Calls() = %d, want 0", got)
+	}
+}
+
+func TestObserver_ObserveRedelivered(t *testing.T) {
+	repo := &accruals{statuses: map[string]model.OrderStatus{}, accruals: map[string]money.Money{}}
+	client := NewFakeClient().
+		AddResult(Result{Order: "70482", Status: StatusProcessed, Accrual: money.Money(50000)})
+
+	q := queue.NewMemory(10, 3)
+	defer q.Close()
+
+	observer := NewObserver(client, q, 0, Polling{MaxAttempts: 5}, NewRateLimiter(0), time.Millisecond, order.New(repo), logger.New())
+
+	messages, err := q.Consume()
+	if err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = q.Publish(context.Background(), []byte("70482")); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		if err = observer.Observe(context.Background(), <-messages); err != nil {
+			t.Errorf("Observe() delivery %d error = %v", i+1, err)
+		}
+	}
+
+	if repo.accruals["70482"] != money.Money(50000) {
+		t.Errorf("accrual = %s, want 500", repo.accruals["70482"])
+	}
+
+	deadLetters, err := q.DeadLetters(context.Background(), 10)
+	if err != nil || len(deadLetters) != 0 {
+		t.Errorf("DeadLetters() = %v, %v, want none", deadLetters, err)
+	}
+}
+
+func TestObserver_ObservePollStateAcrossInstances(t *testing.T) {
+	repo := &accruals{statuses: map[string]model.OrderStatus{}, accruals: map[string]money.Money{}}
+	client := NewFakeClient().
+		AddResult(Result{Order: "70482", Status: StatusProcessing}).
+		AddResult(Result{Order: "70482", Status: StatusProcessing})
+
+	q := queue.NewMemory(10, 3)
+	defer q.Close()
+
+	// Каждая попытка обрабатывается новым экземпляром наблюдателя без общего состояния
+	newObserver := func() *Observer {
+		return NewObserver(client, q, 0, Polling{MaxAttempts: 2, MaxDelay: time.Millisecond}, NewRateLimiter(0), time.Millisecond, order.New(repo), logger.New())
+	}
+
+	messages, err := q.Consume()
+	if err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+
+	if err = q.Publish(context.Background(), []byte("70482")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	if err = newObserver().Observe(context.Background(), <-messages); err != nil {
+		t.Fatalf("Observe() first attempt error = %v", err)
+	}
+
+	var rescheduled queue.Message
+	select {
+	case rescheduled = <-messages:
+	case <-time.After(2 * time.Second):
+		t.Fatal("rescheduled message not received")
+	}
+
+	if attempt := rescheduled.Headers()[headerPollAttempt]; attempt != "1" {
+		t.Errorf("rescheduled attempt = %q, want 1", attempt)
+	}
+
+	if err = newObserver().Observe(context.Background(), rescheduled); err != ErrPollingExhausted {
+		t.Errorf("Observe() second attempt error = %v, want %v", err, ErrPollingExhausted)
+	}
+
+	deadLetters, err := q.DeadLetters(context.Background(), 10)
+	if err != nil || len(deadLetters) != 1 {
+		t.Errorf("DeadLetters() = %v, %v, want exhausted order", deadLetters, err)
+	}
+}
+
+func TestObserver_MaxBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		polling Polling
+		want    time.Duration
+	}{
+		{name: "limited by max delay", polling: Polling{MaxDelay: time.Minute}, want: time.Minute},
+		{name: "limited by attempts", polling: Polling{MaxAttempts: 4, MaxDelay: time.Hour}, want: 8 * time.Second},
+		{name: "single attempt", polling: Polling{MaxAttempts: 1}, want: 2 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			observer := NewObserver(NewFakeClient(), nil, 2, tt.polling, NewRateLimiter(0), time.Second, nil, logger.New())
+			if got := observer.MaxBackoff(); got != tt.want {
+				t.Errorf("MaxBackoff() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
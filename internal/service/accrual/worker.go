@@ -2,51 +2,194 @@ package accrual
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/casnerano/yandex-gophermart/internal/service/queue"
 	"github.com/casnerano/yandex-gophermart/pkg/logger"
 )
 
+const defaultJobTimeout = 15 * time.Minute
+
+// Batching группировка заданий в один запрос к системе начислений: обработчик
+// ожидает до Size заданий, но не дольше Window после получения первого.
+type Batching struct {
+	Size   int
+	Window time.Duration
+}
+
+// WorkerManager пул обработчиков очереди начислений с ограниченным количеством
+// одновременно выполняемых заданий.
 type WorkerManager struct {
-	rabbitmq *queue.RabbitMQ
-	observer *Observer
-	logger   logger.Logger
+	consumer   queue.Consumer
+	observer   *Observer
+	workers    int
+	batching   Batching
+	jobTimeout time.Duration
+	logger     logger.Logger
+
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+	wg         sync.WaitGroup
 }
 
 func NewWorkerManager(
-	rabbitmq *queue.RabbitMQ,
+	consumer queue.Consumer,
 	observer *Observer,
+	workers int,
+	batching Batching,
+	jobTimeout time.Duration,
 	logger logger.Logger,
 ) *WorkerManager {
+	workers, batching = withDefaultConcurrency(workers, batching)
+	jobTimeout = JobTimeout(jobTimeout)
+
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
 	return &WorkerManager{
-		rabbitmq: rabbitmq,
-		observer: observer,
-		logger:   logger,
+		consumer:   consumer,
+		observer:   observer,
+		workers:    workers,
+		batching:   batching,
+		jobTimeout: jobTimeout,
+		logger:     logger,
+		jobsCtx:    jobsCtx,
+		cancelJobs: cancelJobs,
+	}
+}
+
+// JobTimeout время обработки одного задания с учетом значения по умолчанию.
+func JobTimeout(jobTimeout time.Duration) time.Duration {
+	if jobTimeout <= 0 {
+		return defaultJobTimeout
+	}
+	return jobTimeout
+}
+
+// Prefetch количество неподтвержденных сообщений, при котором каждый из workers обработчиков
+// получает полный пакет. Значения по умолчанию применяются так же, как в NewWorkerManager.
+func Prefetch(workers int, batching Batching) int {
+	workers, batching = withDefaultConcurrency(workers, batching)
+	return workers * batching.Size
+}
+
+func withDefaultConcurrency(workers int, batching Batching) (int, Batching) {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	if batching.Size <= 0 {
+		batching.Size = 1
 	}
+
+	return workers, batching
 }
 
+// StartWorker запускает обработчики. После отмены ctx новые задания не принимаются,
+// начатые задания завершаются независимо от ctx, см. Shutdown.
 func (wm *WorkerManager) StartWorker(ctx context.Context) {
-	wm.logger.Info("Started accrual queue worker")
+	messages, err := wm.consumer.Consume()
+	if err != nil {
+		wm.logger.Alert("Failed to start accrual worker", err)
+		return
+	}
+
+	wm.logger.Info(fmt.Sprintf("Started %d accrual queue workers", wm.workers))
+	for i := 0; i < wm.workers; i++ {
+		wm.wg.Add(1)
+		go func() {
+			defer wm.wg.Done()
+			for {
+				// Пока система начислений недоступна, задания остаются в очереди
+				if err := wm.observer.Ready(ctx); err != nil {
+					return
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case message, ok := <-messages:
+					if !ok {
+						wm.logger.Info("Accrual queue closed, stopped accrual worker")
+						return
+					}
+					batch, ok := wm.collect(ctx, messages, message)
+					wm.process(batch)
+					if !ok {
+						wm.logger.Info("Accrual queue closed, stopped accrual worker")
+						return
+					}
+				}
+			}
+		}()
+	}
+}
+
+// Shutdown ожидает завершения выполняемых заданий. Если ctx истекает раньше,
+// оставшиеся задания отменяются и возвращаются в очередь.
+func (wm *WorkerManager) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
 	go func() {
-		messages, err := wm.rabbitmq.Consume()
-		if err != nil {
-			wm.logger.Alert("Failed to start accrual worker", err)
-			return
-		}
+		wm.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		wm.cancelJobs()
+		wm.logger.Info("Stopped accrual workers")
+		return nil
+	case <-ctx.Done():
+		wm.cancelJobs()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Дополняет пакет заданиями из очереди, пока он не заполнится или не истечет окно ожидания.
+// Возвращает false, если очередь закрыта.
+func (wm *WorkerManager) collect(
+	ctx context.Context,
+	messages <-chan queue.Message,
+	first queue.Message,
+) ([]queue.Message, bool) {
+	batch := []queue.Message{first}
+	if wm.batching.Size <= 1 {
+		return batch, true
+	}
 
-		for {
-			select {
-			case <-ctx.Done():
-				wm.logger.Info("Stopped accrual worker")
-				return
-			case message := <-messages:
-				ctxObserver, cancelObserver := context.WithTimeout(ctx, time.Minute*15)
-				defer cancelObserver()
-
-				wm.logger.Info("Received message from queue and start observe", string(message.Body))
-				go wm.observer.Observe(ctxObserver, message)
+	timer := time.NewTimer(wm.batching.Window)
+	defer timer.Stop()
+
+	for len(batch) < wm.batching.Size {
+		select {
+		case <-ctx.Done():
+			return batch, true
+		case <-timer.C:
+			return batch, true
+		case message, ok := <-messages:
+			if !ok {
+				return batch, false
 			}
+			batch = append(batch, message)
 		}
-	}()
+	}
+
+	return batch, true
+}
+
+func (wm *WorkerManager) process(batch []queue.Message) {
+	ctx, cancel := context.WithTimeout(wm.jobsCtx, wm.jobTimeout)
+	defer cancel()
+
+	numbers := make([]string, 0, len(batch))
+	for _, message := range batch {
+		numbers = append(numbers, string(message.Body()))
+	}
+
+	wm.logger.Info("Received messages from queue and start observe", strings.Join(numbers, ", "))
+	if err := wm.observer.ObserveBatch(ctx, batch); err != nil {
+		wm.logger.Warning(fmt.Sprintf("Failed observe orders \"%s\"", strings.Join(numbers, ", ")), err)
+	}
 }
@@ -0,0 +1,25 @@
+package accrual
+
+import "testing"
+
+func TestPrefetch(t *testing.T) {
+	tests := []struct {
+		name     string
+		workers  int
+		batching Batching
+		want     int
+	}{
+		{name: "workers and batch", workers: 10, batching: Batching{Size: 5}, want: 50},
+		{name: "without batching", workers: 10, want: 10},
+		{name: "zero workers", workers: 0, batching: Batching{Size: 5}, want: 5},
+		{name: "negative workers", workers: -1, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Prefetch(tt.workers, tt.batching); got != tt.want {
+				t.Errorf("Prefetch() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
 )
 
 type Balance struct {
@@ -12,8 +13,8 @@ type Balance struct {
 }
 
 type Summary struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+	Current   money.Money `json:"current"`
+	Withdrawn money.Money `json:"withdrawn"`
 }
 
 func New(users repository.User, withdraws repository.Withdraw) *Balance {
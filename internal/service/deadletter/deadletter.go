@@ -0,0 +1,43 @@
+package deadletter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/casnerano/yandex-gophermart/internal/service/queue"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+// DeadLetter управление заказами, обработка которых в очереди начислений окончательно не удалась.
+type DeadLetter struct {
+	queue  queue.DeadLetterQueue
+	logger logger.Logger
+}
+
+func New(queue queue.DeadLetterQueue, logger logger.Logger) *DeadLetter {
+	return &DeadLetter{queue: queue, logger: logger}
+}
+
+func (d *DeadLetter) FindAll(ctx context.Context, limit int) ([]*queue.DeadLetter, error) {
+	return d.queue.DeadLetters(ctx, limit)
+}
+
+func (d *DeadLetter) FindByOrderNumber(ctx context.Context, number string) (*queue.DeadLetter, error) {
+	return d.queue.FindDeadLetter(ctx, []byte(number))
+}
+
+func (d *DeadLetter) Replay(ctx context.Context, number string) error {
+	err := d.queue.ReplayDeadLetter(ctx, []byte(number))
+	if err == nil {
+		d.logger.Notice(fmt.Sprintf("Dead-lettered order \"%s\" replayed", number))
+	}
+	return err
+}
+
+func (d *DeadLetter) Discard(ctx context.Context, number string) error {
+	err := d.queue.DiscardDeadLetter(ctx, []byte(number))
+	if err == nil {
+		d.logger.Notice(fmt.Sprintf("Dead-lettered order \"%s\" discarded", number))
+	}
+	return err
+}
@@ -0,0 +1,184 @@
+package events
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+)
+
+var (
+	ErrClosed         = errors.New("events hub closed")
+	ErrSlowSubscriber = errors.New("subscriber is too slow to receive events")
+)
+
+// Hub рассылка событий подписчикам внутри процесса. Последние события хранятся
+// для возобновления подписки после переподключения клиента.
+//
+// Публикация не блокируется: если подписчик не успевает читать события, его подписка
+// закрывается, и клиент продолжает с последнего полученного события после переподключения.
+type Hub struct {
+	historySize int
+	bufferSize  int
+
+	mu          sync.Mutex
+	subscribers map[string]map[*Subscription]struct{}
+	history     []*model.UserEvent
+	closed      bool
+}
+
+func NewHub(historySize, bufferSize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+
+	return &Hub{
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subscribers: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Subscription подписка на события одного пользователя.
+type Subscription struct {
+	hub      *Hub
+	userUUID string
+	events   chan *model.UserEvent
+	err      error
+	once     sync.Once
+}
+
+// Events канал событий, закрывается при отмене подписки, закрытии Hub
+// или если подписчик не успевает читать события.
+func (s *Subscription) Events() <-chan *model.UserEvent {
+	return s.events
+}
+
+// Err причина закрытия канала событий: ErrClosed, ErrSlowSubscriber
+// или nil, если подписка отменена вызовом Close.
+func (s *Subscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s, nil)
+}
+
+// Subscribe подписывает на события пользователя. Если lastEventID больше нуля,
+// также возвращает сохраненные события пользователя, полученные после события lastEventID.
+// Если такого события уже нет в истории, возвращаются события с большими номерами.
+func (h *Hub) Subscribe(userUUID string, lastEventID int64) (*Subscription, []*model.UserEvent, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, nil, ErrClosed
+	}
+
+	var missed []*model.UserEvent
+	if lastEventID > 0 {
+		missed = h.since(userUUID, lastEventID)
+	}
+
+	subscription := &Subscription{
+		hub:      h,
+		userUUID: userUUID,
+		events:   make(chan *model.UserEvent, h.bufferSize),
+	}
+
+	if h.subscribers[userUUID] == nil {
+		h.subscribers[userUUID] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[userUUID][subscription] = struct{}{}
+
+	return subscription, missed, nil
+}
+
+func (h *Hub) Publish(event *model.UserEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	if h.historySize > 0 {
+		if len(h.history) == h.historySize {
+			copy(h.history, h.history[1:])
+			h.history = h.history[:h.historySize-1]
+		}
+		h.history = append(h.history, event)
+	}
+
+	for subscription := range h.subscribers[event.UserUUID] {
+		select {
+		case subscription.events <- event:
+		default:
+			h.remove(subscription, ErrSlowSubscriber)
+		}
+	}
+}
+
+// Subscribers количество активных подписок.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	count := 0
+	for _, subscriptions := range h.subscribers {
+		count += len(subscriptions)
+	}
+	return count
+}
+
+// Close закрывает все подписки, новые подписки не принимаются.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subscriptions := range h.subscribers {
+		for subscription := range subscriptions {
+			h.remove(subscription, ErrClosed)
+		}
+	}
+}
+
+// События приходят от разных экземпляров не строго по возрастанию номеров,
+// поэтому сначала ищется позиция самого события lastEventID.
+func (h *Hub) since(userUUID string, lastEventID int64) []*model.UserEvent {
+	start := -1
+	for i, event := range h.history {
+		if event.ID == lastEventID {
+			start = i + 1
+			break
+		}
+	}
+
+	missed := make([]*model.UserEvent, 0)
+	for i, event := range h.history {
+		if event.UserUUID != userUUID {
+			continue
+		}
+		if start >= 0 && i >= start || start < 0 && event.ID > lastEventID {
+			missed = append(missed, event)
+		}
+	}
+	return missed
+}
+
+func (h *Hub) remove(subscription *Subscription, err error) {
+	subscription.once.Do(func() {
+		subscription.err = err
+		subscriptions := h.subscribers[subscription.userUUID]
+		delete(subscriptions, subscription)
+		if len(subscriptions) == 0 {
+			delete(h.subscribers, subscription.userUUID)
+		}
+		close(subscription.events)
+	})
+}
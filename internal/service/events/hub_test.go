@@ -0,0 +1,59 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub(3, 2)
+
+	subscription, missed, err := hub.Subscribe("user", 0)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if len(missed) != 0 {
+		t.Errorf("Subscribe() missed = %d, want 0", len(missed))
+	}
+
+	hub.Publish(&model.UserEvent{ID: 1, UserUUID: "user"})
+	hub.Publish(&model.UserEvent{ID: 2, UserUUID: "another"})
+	hub.Publish(&model.UserEvent{ID: 4, UserUUID: "user"})
+	hub.Publish(&model.UserEvent{ID: 3, UserUUID: "user"})
+
+	if event := <-subscription.Events(); event.ID != 1 {
+		t.Errorf("event = %d, want 1", event.ID)
+	}
+	if event := <-subscription.Events(); event.ID != 4 {
+		t.Errorf("event = %d, want 4", event.ID)
+	}
+
+	// Третье событие не поместилось в буфер, подписка закрыта
+	if _, ok := <-subscription.Events(); ok || subscription.Err() != ErrSlowSubscriber {
+		t.Errorf("slow subscription is not closed, error = %v", subscription.Err())
+	}
+	if got := hub.Subscribers(); got != 0 {
+		t.Errorf("Subscribers() = %d, want 0", got)
+	}
+
+	// Событие 1 вытеснено из истории, возвращаются события с большими номерами
+	_, missed, _ = hub.Subscribe("user", 1)
+	if len(missed) != 2 || missed[0].ID != 4 || missed[1].ID != 3 {
+		t.Errorf("Subscribe() missed = %v, want [4 3]", missed)
+	}
+
+	// Событие 3 получено позже события 4, после него ничего не пропущено
+	_, missed, _ = hub.Subscribe("user", 3)
+	if len(missed) != 0 {
+		t.Errorf("Subscribe() missed = %v, want none", missed)
+	}
+
+	hub.Close()
+	if _, _, err = hub.Subscribe("user", 0); err != ErrClosed {
+		t.Errorf("Subscribe() error = %v, want %v", err, ErrClosed)
+	}
+	if got := hub.Subscribers(); got != 0 {
+		t.Errorf("Subscribers() after Close() = %d, want 0", got)
+	}
+}
@@ -0,0 +1,138 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/service/health"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+const (
+	minListenBackoff = 500 * time.Millisecond
+	maxListenBackoff = 30 * time.Second
+)
+
+// Listener получает события всех экземпляров приложения через Postgres LISTEN/NOTIFY
+// и публикует их в Hub. События, отправленные во время переподключения, теряются.
+type Listener struct {
+	pgxpool *pgxpool.Pool
+	channel string
+	hub     *Hub
+	logger  logger.Logger
+
+	mu             sync.Mutex
+	connected      bool
+	lastErr        error
+	disconnectedAt time.Time
+}
+
+func NewListener(pgxpool *pgxpool.Pool, channel string, hub *Hub, logger logger.Logger) *Listener {
+	return &Listener{
+		pgxpool: pgxpool,
+		channel: channel,
+		hub:     hub,
+		logger:  logger,
+	}
+}
+
+func (l *Listener) Start(ctx context.Context) {
+	l.logger.Info(fmt.Sprintf("Started listening user events on \"%s\"", l.channel))
+	go func() {
+		backoff := minListenBackoff
+		for {
+			startedAt := time.Now()
+			err := l.listen(ctx)
+			if ctx.Err() != nil {
+				l.logger.Info("Stopped listening user events")
+				return
+			}
+
+			l.setDisconnected(err)
+			l.logger.Warning(fmt.Sprintf("User events listener disconnected, reconnecting in %s", backoff), err)
+
+			// Соединение проработало достаточно долго, отсчет задержки начинается заново
+			if time.Since(startedAt) > maxListenBackoff {
+				backoff = minListenBackoff
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			if backoff *= 2; backoff > maxListenBackoff {
+				backoff = maxListenBackoff
+			}
+		}
+	}()
+}
+
+// Check отчет о состоянии подписки на события. Без подписки перестают доставляться только
+// уведомления пользователям, поэтому приложение считается работающим с ограничениями.
+func (l *Listener) Check(_ context.Context) health.Report {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	details := map[string]any{"subscribers": l.hub.Subscribers()}
+	if l.connected {
+		return health.Report{Status: health.StatusUp, Details: details}
+	}
+
+	if l.lastErr != nil {
+		details["error"] = l.lastErr.Error()
+		details["disconnected_at"] = l.disconnectedAt.Format(time.RFC3339)
+	}
+	return health.Report{Status: health.StatusDegraded, Details: details}
+}
+
+func (l *Listener) listen(ctx context.Context) error {
+	conn, err := l.pgxpool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Соединение в режиме LISTEN не возвращается в пул
+	pgConn := conn.Hijack()
+	defer pgConn.Close(context.Background())
+
+	if _, err = pgConn.Exec(ctx, "listen "+pgx.Identifier{l.channel}.Sanitize()); err != nil {
+		return err
+	}
+
+	l.setConnected()
+	for {
+		notification, err := pgConn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		event := &model.UserEvent{}
+		if err = json.Unmarshal([]byte(notification.Payload), event); err != nil {
+			l.logger.Error("Failed unmarshal user event", err)
+			continue
+		}
+
+		l.hub.Publish(event)
+	}
+}
+
+func (l *Listener) setConnected() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.connected, l.lastErr = true, nil
+}
+
+func (l *Listener) setDisconnected(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.connected, l.lastErr, l.disconnectedAt = false, err, time.Now()
+}
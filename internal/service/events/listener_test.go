@@ -0,0 +1,17 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/casnerano/yandex-gophermart/internal/service/health"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+func TestListener_CheckDisconnectedIsDegraded(t *testing.T) {
+	listener := NewListener(nil, "user_events", NewHub(10, 10), logger.New())
+
+	if report := listener.Check(context.Background()); report.Status != health.StatusDegraded {
+		t.Errorf("Check() status = %s, want %s", report.Status, health.StatusDegraded)
+	}
+}
@@ -0,0 +1,75 @@
+package health
+
+import (
+	"context"
+	"sync"
+)
+
+type Status string
+
+const (
+	StatusUp       Status = "UP"
+	StatusDegraded Status = "DEGRADED"
+	StatusDown     Status = "DOWN"
+)
+
+// Report состояние отдельного компонента приложения.
+type Report struct {
+	Status  Status         `json:"status"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+type Checker interface {
+	Check(ctx context.Context) Report
+}
+
+type CheckerFunc func(ctx context.Context) Report
+
+func (f CheckerFunc) Check(ctx context.Context) Report {
+	return f(ctx)
+}
+
+type Summary struct {
+	Status     Status            `json:"status"`
+	Components map[string]Report `json:"components"`
+}
+
+type Health struct {
+	mu       sync.RWMutex
+	checkers map[string]Checker
+}
+
+func New() *Health {
+	return &Health{checkers: make(map[string]Checker)}
+}
+
+func (h *Health) Register(name string, checker Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checkers[name] = checker
+}
+
+// Check опрашивает все компоненты. Общий статус равен худшему из статусов компонентов.
+func (h *Health) Check(ctx context.Context) *Summary {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	summary := Summary{
+		Status:     StatusUp,
+		Components: make(map[string]Report, len(h.checkers)),
+	}
+
+	for name, checker := range h.checkers {
+		report := checker.Check(ctx)
+		summary.Components[name] = report
+
+		switch {
+		case report.Status == StatusDown:
+			summary.Status = StatusDown
+		case report.Status == StatusDegraded && summary.Status == StatusUp:
+			summary.Status = StatusDegraded
+		}
+	}
+
+	return &summary
+}
@@ -0,0 +1,110 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+var (
+	ErrKeyReused  = errors.New("idempotency key reused with different request")
+	ErrInProgress = errors.New("request with idempotency key in progress")
+)
+
+// Idempotency хранит ответы на запросы с ключом идемпотентности в течение ttl.
+// Пока запрос обрабатывается, ключ резервируется на более короткий срок lease,
+// чтобы после падения процесса запрос можно было повторить, не дожидаясь истечения ttl.
+type Idempotency struct {
+	keys   repository.IdempotencyKey
+	ttl    time.Duration
+	lease  time.Duration
+	logger logger.Logger
+}
+
+func New(keys repository.IdempotencyKey, ttl, lease time.Duration, logger logger.Logger) *Idempotency {
+	if lease <= 0 || lease > ttl {
+		lease = ttl
+	}
+	return &Idempotency{keys: keys, ttl: ttl, lease: lease, logger: logger}
+}
+
+// Fingerprint отпечаток запроса, по которому определяется повторное использование ключа.
+func Fingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Begin резервирует ключ за запросом. Для уже обработанного запроса
+// возвращает сохраненный ответ, который нужно воспроизвести клиенту.
+func (i *Idempotency) Begin(ctx context.Context, userUUID, key, fingerprint string) (*model.IdempotencyKey, error) {
+	idempotencyKey, err := i.keys.Acquire(ctx, userUUID, key, fingerprint, i.lease)
+	if err == nil {
+		return nil, nil
+	}
+
+	if !errors.Is(err, repository.ErrAlreadyExist) {
+		return nil, err
+	}
+
+	if idempotencyKey.Fingerprint != fingerprint {
+		return nil, ErrKeyReused
+	}
+
+	if !idempotencyKey.Completed() {
+		return nil, ErrInProgress
+	}
+
+	return idempotencyKey, nil
+}
+
+func (i *Idempotency) Complete(ctx context.Context, userUUID, key string, statusCode int, responseBody []byte) error {
+	return i.keys.Complete(ctx, userUUID, key, statusCode, responseBody, i.ttl)
+}
+
+// Release освобождает ключ, чтобы запрос, завершившийся внутренней ошибкой, можно было повторить.
+func (i *Idempotency) Release(ctx context.Context, userUUID, key string) error {
+	return i.keys.Release(ctx, userUUID, key)
+}
+
+// StartCleaner периодически удаляет ключи с истекшим сроком хранения.
+func (i *Idempotency) StartCleaner(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		i.logger.Warning("Idempotency keys cleaner disabled: cleanup interval is not set")
+		return
+	}
+
+	i.logger.Info("Started idempotency keys cleaner")
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				i.logger.Info("Stopped idempotency keys cleaner")
+				return
+			case <-ticker.C:
+				deleted, err := i.keys.DeleteExpired(ctx)
+				if err != nil {
+					i.logger.Error("Failed to delete expired idempotency keys", err)
+					continue
+				}
+				if deleted > 0 {
+					i.logger.Info(fmt.Sprintf("Deleted %d expired idempotency keys", deleted))
+				}
+			}
+		}
+	}()
+}
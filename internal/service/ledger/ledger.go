@@ -0,0 +1,94 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
+
+var (
+	ErrZeroAmount  = errors.New("ledger adjustment amount must not be zero")
+	ErrEmptyReason = errors.New("ledger operation reason must not be empty")
+)
+
+type Ledger struct {
+	ledger repository.Ledger
+	logger logger.Logger
+}
+
+func New(ledger repository.Ledger, logger logger.Logger) *Ledger {
+	return &Ledger{ledger: ledger, logger: logger}
+}
+
+// Adjust проводит ручную корректировку баланса пользователя,
+// положительная сумма начисляет баллы, отрицательная списывает.
+func (l *Ledger) Adjust(ctx context.Context, userUUID string, amount money.Money, reason string) (*model.LedgerEntry, error) {
+	if amount.IsZero() {
+		return nil, ErrZeroAmount
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrEmptyReason
+	}
+
+	entry, err := l.ledger.Adjust(ctx, userUUID, amount, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	l.logger.Info(fmt.Sprintf("Ledger adjustment \"%s\" of %s for user \"%s\": %s", entry.UUID, amount, userUUID, reason))
+	return entry, nil
+}
+
+// Reverse проводит сторнирующую проводку, каждую проводку можно сторнировать только один раз.
+func (l *Ledger) Reverse(ctx context.Context, entryUUID string, reason string) (*model.LedgerEntry, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrEmptyReason
+	}
+
+	entry, err := l.ledger.Reverse(ctx, entryUUID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	l.logger.Info(fmt.Sprintf("Ledger entry \"%s\" reversed by \"%s\": %s", entryUUID, entry.UUID, reason))
+	return entry, nil
+}
+
+func (l *Ledger) FindByUUID(ctx context.Context, uuid string) (*model.LedgerEntry, error) {
+	return l.ledger.FindByUUID(ctx, uuid)
+}
+
+func (l *Ledger) FindAllByUserUUID(ctx context.Context, userUUID string) ([]*model.LedgerEntry, error) {
+	return l.ledger.FindAllByUserUUID(ctx, userUUID)
+}
+
+// Verify сверяет кэшированные балансы пользователей с суммами их проводок
+// и возвращает найденные расхождения.
+func (l *Ledger) Verify(ctx context.Context) ([]*model.LedgerDiscrepancy, error) {
+	discrepancies, err := l.ledger.FindDiscrepancies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, discrepancy := range discrepancies {
+		l.logger.Critical(
+			fmt.Sprintf(
+				"Ledger discrepancy for user \"%s\": balance %s, ledger %s",
+				discrepancy.UserUUID,
+				discrepancy.Balance,
+				discrepancy.LedgerBalance,
+			),
+		)
+	}
+
+	return discrepancies, nil
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Type string
+
+const (
+	TypeGauge   Type = "gauge"
+	TypeCounter Type = "counter"
+)
+
+// Sample значение метрики в момент сбора.
+type Sample struct {
+	Name   string
+	Help   string
+	Type   Type
+	Labels map[string]string
+	Value  float64
+}
+
+type Collector interface {
+	Collect() []Sample
+}
+
+type CollectorFunc func() []Sample
+
+func (f CollectorFunc) Collect() []Sample {
+	return f()
+}
+
+// Metrics реестр компонентов, отдающих метрики в текстовом формате Prometheus.
+type Metrics struct {
+	mu         sync.RWMutex
+	collectors []Collector
+}
+
+func New() *Metrics {
+	return &Metrics{}
+}
+
+func (m *Metrics) Register(collector Collector) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.collectors = append(m.collectors, collector)
+}
+
+// Write собирает метрики всех компонентов, значения одной метрики группируются под общим описанием.
+func (m *Metrics) Write(w io.Writer) error {
+	m.mu.RLock()
+	samples := make([]Sample, 0, len(m.collectors))
+	for _, collector := range m.collectors {
+		samples = append(samples, collector.Collect()...)
+	}
+	m.mu.RUnlock()
+
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Name < samples[j].Name
+	})
+
+	for i, sample := range samples {
+		if i == 0 || samples[i-1].Name != sample.Name {
+			if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", sample.Name, sample.Help, sample.Name, sample.Type); err != nil {
+				return err
+			}
+		}
+
+		value := strconv.FormatFloat(sample.Value, 'g', -1, 64)
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", sample.Name, formatLabels(sample.Labels), value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, strconv.Quote(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
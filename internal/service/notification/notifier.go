@@ -0,0 +1,224 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/service/balance"
+	"github.com/casnerano/yandex-gophermart/internal/service/events"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
+
+type MessageType string
+
+const (
+	MessageBalance    MessageType = "balance"
+	MessageOrder      MessageType = "order"
+	MessageWithdrawal MessageType = "withdrawal"
+)
+
+var (
+	ErrShutdown     = errors.New("notifications are shutting down")
+	ErrSlowConsumer = errors.New("notification consumer is too slow")
+)
+
+const balanceTimeout = 5 * time.Second
+
+// Message уведомление пользователя. ID номер события, вызвавшего уведомление.
+type Message struct {
+	Type MessageType `json:"type"`
+	ID   int64       `json:"id,omitempty"`
+	Data any         `json:"data"`
+}
+
+// Notifier преобразует события пользователей в уведомления для постоянных соединений.
+// Изменение заказа с начислением и списание сопровождаются уведомлением с новым балансом.
+type Notifier struct {
+	hub        *events.Hub
+	balance    *balance.Balance
+	bufferSize int
+	logger     logger.Logger
+
+	mu       sync.Mutex
+	sessions map[*Session]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+func New(hub *events.Hub, balance *balance.Balance, bufferSize int, logger logger.Logger) *Notifier {
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+
+	return &Notifier{
+		hub:        hub,
+		balance:    balance,
+		bufferSize: bufferSize,
+		logger:     logger,
+		sessions:   make(map[*Session]struct{}),
+	}
+}
+
+// Session уведомления одного соединения пользователя.
+type Session struct {
+	notifier     *Notifier
+	userUUID     string
+	subscription *events.Subscription
+	messages     chan Message
+	stop         chan struct{}
+	stopOnce     sync.Once
+	closeOnce    sync.Once
+
+	mu  sync.Mutex
+	err error
+}
+
+// Open открывает сессию уведомлений пользователя. Сессию нужно закрыть вызовом Close.
+func (n *Notifier) Open(userUUID string) (*Session, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return nil, ErrShutdown
+	}
+
+	subscription, _, err := n.hub.Subscribe(userUUID, 0)
+	if err != nil {
+		return nil, ErrShutdown
+	}
+
+	session := &Session{
+		notifier:     n,
+		userUUID:     userUUID,
+		subscription: subscription,
+		messages:     make(chan Message, n.bufferSize),
+		stop:         make(chan struct{}),
+	}
+	n.sessions[session] = struct{}{}
+	n.wg.Add(1)
+
+	go session.run()
+	return session, nil
+}
+
+// Shutdown завершает все сессии и ожидает их закрытия.
+func (n *Notifier) Shutdown(ctx context.Context) error {
+	n.mu.Lock()
+	n.closed = true
+	for session := range n.sessions {
+		session.fail(ErrShutdown)
+	}
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Messages канал уведомлений, закрывается при завершении сессии, причина в Err.
+func (s *Session) Messages() <-chan Message {
+	return s.messages
+}
+
+// Err причина завершения сессии: ErrShutdown, ErrSlowConsumer или nil, если сессия закрыта вызовом Close.
+func (s *Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *Session) Close() {
+	s.closeOnce.Do(func() {
+		s.fail(nil)
+		s.subscription.Close()
+
+		s.notifier.mu.Lock()
+		delete(s.notifier.sessions, s)
+		s.notifier.mu.Unlock()
+		s.notifier.wg.Done()
+	})
+}
+
+// Уведомления, которые не помещаются в буфер, не откладываются: медленное соединение
+// закрывается, и клиент после переподключения запрашивает актуальное состояние.
+func (s *Session) run() {
+	defer close(s.messages)
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case event, ok := <-s.subscription.Events():
+			if !ok {
+				if errors.Is(s.subscription.Err(), events.ErrSlowSubscriber) {
+					s.fail(ErrSlowConsumer)
+				} else {
+					s.fail(ErrShutdown)
+				}
+				return
+			}
+
+			for _, message := range s.notifier.messages(event) {
+				select {
+				case s.messages <- message:
+				default:
+					s.fail(ErrSlowConsumer)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Session) fail(err error) {
+	s.stopOnce.Do(func() {
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		close(s.stop)
+	})
+}
+
+func (n *Notifier) messages(event *model.UserEvent) []Message {
+	var messages []Message
+	switch event.Type {
+	case model.UserEventOrder:
+		messages = append(messages, Message{Type: MessageOrder, ID: event.ID, Data: event.Data})
+
+		order := struct {
+			Accrual money.Money `json:"accrual"`
+		}{}
+		if err := json.Unmarshal(event.Data, &order); err != nil || order.Accrual.IsZero() {
+			return messages
+		}
+	case model.UserEventWithdrawal:
+		messages = append(messages, Message{Type: MessageWithdrawal, ID: event.ID, Data: event.Data})
+	default:
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), balanceTimeout)
+	defer cancel()
+
+	summary, err := n.balance.GetSummaryByUserUUID(ctx, event.UserUUID)
+	if err != nil {
+		n.logger.Error("Failed get user balance for notification", err)
+		return messages
+	}
+
+	return append(messages, Message{Type: MessageBalance, ID: event.ID, Data: summary})
+}
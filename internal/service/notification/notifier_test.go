@@ -0,0 +1,107 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/internal/service/balance"
+	"github.com/casnerano/yandex-gophermart/internal/service/events"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
+)
+
+type users struct {
+	repository.User
+}
+
+func (u *users) FindByUUID(_ context.Context, uuid string) (*model.User, error) {
+	return &model.User{UUID: uuid, Balance: money.Money(50000)}, nil
+}
+
+type withdraws struct {
+	repository.Withdraw
+}
+
+func (w *withdraws) TotalWithdrawnByUserUUID(_ context.Context, _ string) (money.Money, error) {
+	return money.Money(10000), nil
+}
+
+func receive(t *testing.T, session *Session) (Message, bool) {
+	t.Helper()
+	select {
+	case message, ok := <-session.Messages():
+		return message, ok
+	case <-time.After(time.Second):
+		t.Fatalf("message not received")
+		return Message{}, false
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	hub := events.NewHub(0, 10)
+	notifier := New(hub, balance.New(&users{}, &withdraws{}), 3, logger.New())
+
+	session, err := notifier.Open("user")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	hub.Publish(&model.UserEvent{ID: 1, Type: model.UserEventOrder, UserUUID: "user", Data: json.RawMessage(`{"number":"18","status":"PROCESSING"}`)})
+	hub.Publish(&model.UserEvent{ID: 2, Type: model.UserEventOrder, UserUUID: "user", Data: json.RawMessage(`{"number":"26","status":"PROCESSED","accrual":500}`)})
+
+	for _, want := range []MessageType{MessageOrder, MessageOrder, MessageBalance} {
+		message, _ := receive(t, session)
+		if message.Type != want {
+			t.Errorf("message type = %s, want %s", message.Type, want)
+		}
+		if summary, ok := message.Data.(*balance.Summary); ok && summary.Current != money.Money(50000) {
+			t.Errorf("balance = %s, want 500", summary.Current)
+		}
+	}
+
+	// Уведомления о двух списаниях с балансом не помещаются в буфер, сессия закрывается
+	hub.Publish(&model.UserEvent{ID: 3, Type: model.UserEventWithdrawal, UserUUID: "user", Data: json.RawMessage(`{}`)})
+	hub.Publish(&model.UserEvent{ID: 4, Type: model.UserEventWithdrawal, UserUUID: "user", Data: json.RawMessage(`{}`)})
+
+	deadline := time.Now().Add(time.Second)
+	for session.Err() == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	for {
+		if _, ok := receive(t, session); !ok {
+			break
+		}
+	}
+	if !errors.Is(session.Err(), ErrSlowConsumer) {
+		t.Errorf("Err() = %v, want %v", session.Err(), ErrSlowConsumer)
+	}
+	session.Close()
+
+	session, err = notifier.Open("user")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	go func() {
+		for range session.Messages() {
+		}
+		session.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = notifier.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if !errors.Is(session.Err(), ErrShutdown) {
+		t.Errorf("Err() = %v, want %v", session.Err(), ErrShutdown)
+	}
+	if _, err = notifier.Open("user"); !errors.Is(err, ErrShutdown) {
+		t.Errorf("Open() after Shutdown() error = %v, want %v", err, ErrShutdown)
+	}
+}
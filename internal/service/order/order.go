@@ -6,21 +6,30 @@ import (
 
 	"github.com/casnerano/yandex-gophermart/internal/model"
 	"github.com/casnerano/yandex-gophermart/internal/repository"
-	"github.com/casnerano/yandex-gophermart/internal/service/queue"
+	"github.com/casnerano/yandex-gophermart/pkg/luhn"
 )
 
 var (
 	ErrAlreadyUploaded          = errors.New("already uploaded")
 	ErrAlreadyUploadedByAnother = errors.New("already uploaded by another")
+	ErrNotOwner                 = errors.New("order uploaded by another user")
 )
 
+// UploadResult результат добавления заказа из пакета. Err равен nil для добавленного заказа,
+// иначе содержит ту же ошибку, что вернул бы Add.
+type UploadResult struct {
+	Number string
+	Err    error
+}
+
 type Order struct {
-	orders   repository.Order
-	rabbitmq *queue.RabbitMQ
+	orders repository.Order
 }
 
-func New(orders repository.Order, rabbitmq *queue.RabbitMQ) *Order {
-	return &Order{orders: orders, rabbitmq: rabbitmq}
+// New создает сервис заказов. Постановка заказа в очередь начислений
+// выполняется репозиторием в транзакции добавления заказа.
+func New(orders repository.Order) *Order {
+	return &Order{orders: orders}
 }
 
 func (o *Order) Add(ctx context.Context, number, userUUID string) (*model.Order, error) {
@@ -39,10 +48,56 @@ func (o *Order) Add(ctx context.Context, number, userUUID string) (*model.Order,
 		return nil, err
 	}
 
-	_ = o.rabbitmq.Publish(ctx, []byte(order.Number))
 	return order, nil
 }
 
+// AddBatch добавляет заказы пользователя одной транзакцией и возвращает результаты
+// в порядке номеров. Повтор номера в пакете считается уже загруженным заказом.
+func (o *Order) AddBatch(ctx context.Context, numbers []string, userUUID string) ([]UploadResult, error) {
+	results := make([]UploadResult, len(numbers))
+	seen := make(map[string]bool, len(numbers))
+	valid := make([]string, 0, len(numbers))
+	for i, number := range numbers {
+		results[i].Number = number
+		switch {
+		case !luhn.Checksum(number):
+			results[i].Err = repository.ErrOrderIncorrectNumber
+		case seen[number]:
+			results[i].Err = ErrAlreadyUploaded
+		default:
+			seen[number] = true
+			valid = append(valid, number)
+		}
+	}
+
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	_, existing, err := o.orders.AddBatch(ctx, valid, userUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	uploaded := make(map[string]error, len(existing))
+	for _, order := range existing {
+		if order.UserUUID == userUUID {
+			uploaded[order.Number] = ErrAlreadyUploaded
+		} else {
+			uploaded[order.Number] = ErrAlreadyUploadedByAnother
+		}
+	}
+
+	// Повторы уже отмечены, первое вхождение номера получает результат добавления
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Err = uploaded[results[i].Number]
+		}
+	}
+
+	return results, nil
+}
+
 func (o *Order) FindByNumber(ctx context.Context, number string) (*model.Order, error) {
 	return o.orders.FindByNumber(ctx, number)
 }
@@ -51,6 +106,33 @@ func (o *Order) FindAllByUserUUID(ctx context.Context, userUUID string) ([]*mode
 	return o.orders.FindAllByUserUUID(ctx, userUUID)
 }
 
-func (o *Order) AccrueByNumber(ctx context.Context, number string, status model.OrderStatus, accrual float64) (*model.Order, error) {
-	return o.orders.AccrueByNumber(ctx, number, status, accrual)
+func (o *Order) FindPageByUserUUID(
+	ctx context.Context,
+	userUUID string,
+	query repository.OrderQuery,
+) ([]*model.Order, *repository.Cursor, error) {
+	return o.orders.FindPageByUserUUID(ctx, userUUID, query)
+}
+
+// FindUserOrder заказ пользователя вместе с историей его статусов.
+func (o *Order) FindUserOrder(ctx context.Context, number, userUUID string) (*model.Order, []*model.OrderStatusChange, error) {
+	order, err := o.orders.FindByNumber(ctx, number)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if order.UserUUID != userUUID {
+		return nil, nil, ErrNotOwner
+	}
+
+	history, err := o.orders.FindHistoryByNumber(ctx, number)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return order, history, nil
+}
+
+func (o *Order) AccrueByNumber(ctx context.Context, number string, change *model.OrderStatusChange) (*model.Order, error) {
+	return o.orders.AccrueByNumber(ctx, number, change)
 }
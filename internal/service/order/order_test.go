@@ -0,0 +1,50 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+)
+
+type batchOrders struct {
+	repository.Order
+	owners map[string]string
+	added  []string
+}
+
+func (b *batchOrders) AddBatch(_ context.Context, numbers []string, userUUID string) ([]*model.Order, []*model.Order, error) {
+	var added, existing []*model.Order
+	for _, number := range numbers {
+		if owner, found := b.owners[number]; found {
+			existing = append(existing, &model.Order{Number: number, UserUUID: owner})
+			continue
+		}
+		b.owners[number] = userUUID
+		b.added = append(b.added, number)
+		added = append(added, &model.Order{Number: number, UserUUID: userUUID})
+	}
+	return added, existing, nil
+}
+
+func TestOrder_AddBatch(t *testing.T) {
+	orders := &batchOrders{owners: map[string]string{"70482": "user", "18": "another"}}
+
+	results, err := New(orders).AddBatch(context.Background(), []string{"26", "70482", "18", "70483", "26"}, "user")
+	if err != nil {
+		t.Fatalf("AddBatch() error = %v", err)
+	}
+
+	want := []error{nil, ErrAlreadyUploaded, ErrAlreadyUploadedByAnother, repository.ErrOrderIncorrectNumber, ErrAlreadyUploaded}
+	for i, result := range results {
+		if !errors.Is(result.Err, want[i]) || (want[i] == nil && result.Err != nil) {
+			t.Errorf("result %s error = %v, want %v", result.Number, result.Err, want[i])
+		}
+	}
+
+	if len(orders.added) != 1 || orders.added[0] != "26" {
+		t.Errorf("added = %v, want [26]", orders.added)
+	}
+}
@@ -0,0 +1,170 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/internal/service/health"
+	"github.com/casnerano/yandex-gophermart/internal/service/queue"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+// Relay переносит сообщения из таблицы outbox в очередь. Сообщение помечается
+// отправленным только после успешной публикации, при ошибке попытка повторяется позже.
+//
+// Пакет сообщений резервируется на lease отдельным запросом, публикация выполняется
+// вне транзакции и ограничена тем же lease, чтобы недоступность очереди
+// не удерживала соединения и блокировки строк.
+type Relay struct {
+	outbox    repository.Outbox
+	publisher queue.Publisher
+	interval  time.Duration
+	batchSize int
+	lease     time.Duration
+	logger    logger.Logger
+}
+
+func NewRelay(
+	outbox repository.Outbox,
+	publisher queue.Publisher,
+	interval time.Duration,
+	batchSize int,
+	lease time.Duration,
+	logger logger.Logger,
+) *Relay {
+	return &Relay{
+		outbox:    outbox,
+		publisher: publisher,
+		interval:  interval,
+		batchSize: batchSize,
+		lease:     lease,
+		logger:    logger,
+	}
+}
+
+func (r *Relay) Start(ctx context.Context) {
+	if r.interval <= 0 || r.batchSize <= 0 || r.lease <= 0 {
+		r.logger.Warning("Outbox relay disabled: interval, batch size or lease is not set")
+		return
+	}
+
+	r.logger.Info("Started outbox relay")
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				r.logger.Info("Stopped outbox relay")
+				return
+			case <-ticker.C:
+				r.relay(ctx)
+			}
+		}
+	}()
+}
+
+// StartCleaner периодически удаляет сообщения, отправленные раньше чем retention назад.
+func (r *Relay) StartCleaner(ctx context.Context, interval, retention time.Duration) {
+	if interval <= 0 || retention <= 0 {
+		r.logger.Warning("Outbox cleaner disabled: cleanup interval or retention is not set")
+		return
+	}
+
+	r.logger.Info("Started outbox cleaner")
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				r.logger.Info("Stopped outbox cleaner")
+				return
+			case <-ticker.C:
+				deleted, err := r.outbox.DeleteSent(ctx, retention)
+				if err != nil {
+					r.logger.Error("Failed to delete sent outbox messages", err)
+					continue
+				}
+				if deleted > 0 {
+					r.logger.Info(fmt.Sprintf("Deleted %d sent outbox messages", deleted))
+				}
+			}
+		}
+	}()
+}
+
+// Backlog количество сообщений, ожидающих отправки.
+func (r *Relay) Backlog(ctx context.Context) (int64, error) {
+	return r.outbox.CountPending(ctx)
+}
+
+func (r *Relay) Check(ctx context.Context) health.Report {
+	backlog, err := r.Backlog(ctx)
+	if err != nil {
+		return health.Report{
+			Status:  health.StatusDown,
+			Details: map[string]any{"error": err.Error()},
+		}
+	}
+
+	return health.Report{
+		Status:  health.StatusUp,
+		Details: map[string]any{"backlog": backlog},
+	}
+}
+
+func (r *Relay) relay(ctx context.Context) {
+	for {
+		messages, err := r.outbox.ClaimPending(ctx, r.batchSize, r.lease)
+		if err != nil {
+			r.logger.Error("Failed to claim outbox messages", err)
+			return
+		}
+
+		// Публикация пакета не должна выйти за lease, иначе сообщения заберет другой экземпляр
+		publishCtx, cancel := context.WithTimeout(ctx, r.lease)
+		sent := 0
+		for _, message := range messages {
+			if publishCtx.Err() != nil {
+				break
+			}
+			if r.send(ctx, publishCtx, message) {
+				sent++
+			}
+		}
+		cancel()
+
+		// Пакет отправлен не полностью или очередь пуста, следующая попытка по таймеру
+		if sent < r.batchSize {
+			return
+		}
+	}
+}
+
+// Публикует сообщение и сохраняет результат, false если сообщение не отправлено.
+func (r *Relay) send(ctx, publishCtx context.Context, message *model.OutboxMessage) bool {
+	err := r.publisher.Publish(publishCtx, message.Payload)
+	if err != nil {
+		r.logger.Warning(
+			fmt.Sprintf("Failed to publish outbox message \"%s\", attempt %d", message.UUID, message.Attempts+1),
+			err,
+		)
+		if err = r.outbox.MarkFailed(ctx, message.UUID, err); err != nil {
+			r.logger.Error("Failed to save outbox message failure", err)
+		}
+		return false
+	}
+
+	// Если отметка не сохранится, сообщение будет опубликовано повторно по истечении lease
+	if err = r.outbox.MarkSent(ctx, message.UUID); err != nil {
+		r.logger.Error("Failed to mark outbox message sent", err)
+		return false
+	}
+	return true
+}
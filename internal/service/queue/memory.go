@@ -0,0 +1,238 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory буферизованная очередь в памяти процесса для локальной разработки и тестов.
+// Сообщения не переживают перезапуск приложения.
+type Memory struct {
+	messages   chan Message
+	done       chan struct{}
+	closeOnce  sync.Once
+	maxRetries int
+	retryDelay func(attempts int) time.Duration
+
+	mu          sync.Mutex
+	deadLetters []*DeadLetter
+	timers      map[*time.Timer]struct{}
+}
+
+type memoryMessage struct {
+	body     []byte
+	headers  Headers
+	attempts int
+	queue    *Memory
+}
+
+func NewMemory(size int, maxRetries int) *Memory {
+	return &Memory{
+		messages:   make(chan Message, size),
+		done:       make(chan struct{}),
+		maxRetries: maxRetries,
+		retryDelay: retryDelay,
+		timers:     make(map[*time.Timer]struct{}),
+	}
+}
+
+func (m *Memory) Publish(ctx context.Context, body []byte) error {
+	return m.push(ctx, &memoryMessage{body: body, queue: m})
+}
+
+// PublishDelayed добавляет сообщение в очередь по истечении delay,
+// если к этому моменту очередь не закрыта.
+func (m *Memory) PublishDelayed(ctx context.Context, body []byte, headers Headers, delay time.Duration) error {
+	message := &memoryMessage{body: body, headers: make(Headers, len(headers)), queue: m}
+	for key, value := range headers {
+		message.headers[key] = value
+	}
+
+	return m.schedule(message, delay)
+}
+
+func (m *Memory) Consume() (<-chan Message, error) {
+	select {
+	case <-m.done:
+		return nil, ErrClosed
+	default:
+	}
+
+	deliveries := make(chan Message)
+	go func() {
+		defer close(deliveries)
+		for {
+			select {
+			case <-m.done:
+				return
+			case message := <-m.messages:
+				select {
+				case deliveries <- message:
+				case <-m.done:
+					return
+				}
+			}
+		}
+	}()
+
+	return deliveries, nil
+}
+
+func (m *Memory) DeadLetters(_ context.Context, limit int) ([]*DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := len(m.deadLetters)
+	if limit > 0 && limit < count {
+		count = limit
+	}
+
+	deadLetters := make([]*DeadLetter, count)
+	copy(deadLetters, m.deadLetters)
+	return deadLetters, nil
+}
+
+func (m *Memory) FindDeadLetter(_ context.Context, body []byte) (*DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index := m.deadLetterIndex(body)
+	if index < 0 {
+		return nil, ErrDeadLetterNotFound
+	}
+	return m.deadLetters[index], nil
+}
+
+func (m *Memory) ReplayDeadLetter(ctx context.Context, body []byte) error {
+	if err := m.DiscardDeadLetter(ctx, body); err != nil {
+		return err
+	}
+	return m.Publish(ctx, body)
+}
+
+func (m *Memory) DiscardDeadLetter(_ context.Context, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index := m.deadLetterIndex(body)
+	if index < 0 {
+		return ErrDeadLetterNotFound
+	}
+	m.deadLetters = append(m.deadLetters[:index], m.deadLetters[index+1:]...)
+	return nil
+}
+
+// Close закрывает очередь и отменяет отложенные публикации и возвраты сообщений.
+func (m *Memory) Close() error {
+	m.closeOnce.Do(func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		close(m.done)
+		for timer := range m.timers {
+			timer.Stop()
+		}
+		m.timers = nil
+	})
+	return nil
+}
+
+// Добавляет сообщение в очередь по истечении delay, не блокируя вызывающего.
+func (m *Memory) schedule(message *memoryMessage, delay time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	select {
+	case <-m.done:
+		return ErrClosed
+	default:
+	}
+
+	// Обработчик таймера ждет освобождения mu, поэтому timer к его запуску уже присвоен
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		m.mu.Lock()
+		delete(m.timers, timer)
+		m.mu.Unlock()
+
+		_ = m.push(context.Background(), message)
+	})
+	m.timers[timer] = struct{}{}
+	return nil
+}
+
+func (m *Memory) push(ctx context.Context, message Message) error {
+	select {
+	case <-m.done:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case m.messages <- message:
+		return nil
+	case <-m.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Memory) bury(message *memoryMessage, cause error) {
+	deadLetter := &DeadLetter{
+		Body:     message.body,
+		Attempts: message.attempts,
+		DeadAt:   time.Now(),
+	}
+	if cause != nil {
+		deadLetter.LastError = cause.Error()
+	}
+
+	m.mu.Lock()
+	m.deadLetters = append(m.deadLetters, deadLetter)
+	m.mu.Unlock()
+}
+
+func (m *Memory) deadLetterIndex(body []byte) int {
+	for i, deadLetter := range m.deadLetters {
+		if bytes.Equal(deadLetter.Body, body) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (mm *memoryMessage) Body() []byte {
+	return mm.body
+}
+
+func (mm *memoryMessage) Headers() Headers {
+	return mm.headers
+}
+
+func (mm *memoryMessage) Ack() error {
+	return nil
+}
+
+func (mm *memoryMessage) Nack(requeue bool) error {
+	if !requeue {
+		mm.queue.bury(mm, nil)
+		return nil
+	}
+
+	// Возврат в очередь не должен блокировать обработчик при заполненном буфере
+	return mm.queue.schedule(mm, 0)
+}
+
+func (mm *memoryMessage) NackWithError(requeue bool, cause error) error {
+	mm.attempts++
+	if !requeue || mm.queue.maxRetries > 0 && mm.attempts >= mm.queue.maxRetries {
+		mm.queue.bury(mm, cause)
+		return nil
+	}
+
+	// Повторная попытка откладывается, чтобы постоянно падающее сообщение не обрабатывалось в цикле
+	return mm.queue.schedule(mm, mm.queue.retryDelay(mm.attempts))
+}
@@ -0,0 +1,169 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	q := NewMemory(2, 0)
+
+	messages, err := q.Consume()
+	if err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+
+	if err = q.Publish(context.Background(), []byte("70482")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	message := receive(t, messages)
+	if string(message.Body()) != "70482" {
+		t.Errorf("Body() = %s, want 70482", message.Body())
+	}
+
+	if err = message.Nack(true); err != nil {
+		t.Fatalf("Nack() error = %v", err)
+	}
+
+	requeued := receive(t, messages)
+	if string(requeued.Body()) != "70482" {
+		t.Errorf("Body() after requeue = %s, want 70482", requeued.Body())
+	}
+
+	if err = q.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if err = q.Publish(context.Background(), []byte("18")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Publish() after close error = %v, want %v", err, ErrClosed)
+	}
+
+	if _, ok := <-messages; ok {
+		t.Error("messages channel is not closed after Close()")
+	}
+}
+
+func TestMemoryDeadLetter(t *testing.T) {
+	q := NewMemory(2, 2)
+	q.retryDelay = func(int) time.Duration { return 0 }
+	defer q.Close()
+
+	messages, err := q.Consume()
+	if err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+
+	if err = q.Publish(context.Background(), []byte("70482")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	cause := errors.New("accrual unavailable")
+	for i := 0; i < 2; i++ {
+		if err = NackWithError(receive(t, messages), true, cause); err != nil {
+			t.Fatalf("NackWithError() error = %v", err)
+		}
+	}
+
+	deadLetter, err := q.FindDeadLetter(context.Background(), []byte("70482"))
+	if err != nil {
+		t.Fatalf("FindDeadLetter() error = %v", err)
+	}
+
+	if deadLetter.Attempts != 2 || deadLetter.LastError != cause.Error() {
+		t.Errorf("FindDeadLetter() = %+v, want 2 attempts with last error %q", deadLetter, cause)
+	}
+
+	if err = q.ReplayDeadLetter(context.Background(), []byte("70482")); err != nil {
+		t.Fatalf("ReplayDeadLetter() error = %v", err)
+	}
+
+	if string(receive(t, messages).Body()) != "70482" {
+		t.Error("replayed message not received")
+	}
+
+	if _, err = q.FindDeadLetter(context.Background(), []byte("70482")); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("FindDeadLetter() after replay error = %v, want %v", err, ErrDeadLetterNotFound)
+	}
+}
+
+func TestMemoryRequeueDelay(t *testing.T) {
+	q := NewMemory(2, 0)
+	q.retryDelay = func(attempts int) time.Duration { return time.Duration(attempts) * 100 * time.Millisecond }
+
+	messages, err := q.Consume()
+	if err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+
+	if err = q.Publish(context.Background(), []byte("70482")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	cause := errors.New("accrual unavailable")
+	if err = NackWithError(receive(t, messages), true, cause); err != nil {
+		t.Fatalf("NackWithError() error = %v", err)
+	}
+
+	select {
+	case <-messages:
+		t.Fatal("requeued message received before retry delay")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err = NackWithError(receive(t, messages), true, cause); err != nil {
+		t.Fatalf("NackWithError() error = %v", err)
+	}
+
+	if err = q.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	q.mu.Lock()
+	pending := len(q.timers)
+	q.mu.Unlock()
+	if pending != 0 {
+		t.Errorf("pending requeues after Close() = %d, want 0", pending)
+	}
+
+	if _, ok := <-messages; ok {
+		t.Error("requeued message received after Close()")
+	}
+}
+
+func TestMemoryPublishDelayed(t *testing.T) {
+	q := NewMemory(2, 0)
+	defer q.Close()
+
+	messages, err := q.Consume()
+	if err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+
+	if err = q.PublishDelayed(context.Background(), []byte("70482"), nil, 100*time.Millisecond); err != nil {
+		t.Fatalf("PublishDelayed() error = %v", err)
+	}
+
+	select {
+	case <-messages:
+		t.Fatal("delayed message received before delay")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if string(receive(t, messages).Body()) != "70482" {
+		t.Error("delayed message not received")
+	}
+}
+
+func receive(t *testing.T, messages <-chan Message) Message {
+	t.Helper()
+	select {
+	case message := <-messages:
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+		return nil
+	}
+}
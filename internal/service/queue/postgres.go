@@ -0,0 +1,394 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/casnerano/yandex-gophermart/internal/service/health"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+// Execer общий интерфейс пула соединений и транзакции pgx.
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// Postgres очередь заданий в таблице accrual_jobs.
+//
+// Обработчики забирают задания через SELECT ... FOR UPDATE SKIP LOCKED и блокируют их
+// на время visibilityTimeout: если обработчик упал, не подтвердив задание,
+// по истечении таймаута оно снова становится доступным.
+// Задания забираются по одному и блокировка продлевается при передаче обработчику,
+// поэтому visibilityTimeout должен превышать время обработки задания.
+type Postgres struct {
+	pgxpool           *pgxpool.Pool
+	visibilityTimeout time.Duration
+	pollInterval      time.Duration
+	maxAttempts       int
+	logger            logger.Logger
+
+	mu           sync.RWMutex
+	claimError   error
+	failingSince time.Time
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type postgresMessage struct {
+	uuid     string
+	body     []byte
+	headers  Headers
+	attempts int
+	queue    *Postgres
+}
+
+func NewPostgres(
+	pgxpool *pgxpool.Pool,
+	visibilityTimeout time.Duration,
+	pollInterval time.Duration,
+	maxAttempts int,
+	logger logger.Logger,
+) *Postgres {
+	return &Postgres{
+		pgxpool:           pgxpool,
+		visibilityTimeout: visibilityTimeout,
+		pollInterval:      pollInterval,
+		maxAttempts:       maxAttempts,
+		logger:            logger,
+		done:              make(chan struct{}),
+	}
+}
+
+func (p *Postgres) Publish(ctx context.Context, body []byte) error {
+	return p.Enqueue(ctx, p.pgxpool, body)
+}
+
+// Enqueue ставит задание в очередь через переданное соединение,
+// что позволяет сделать это в одной транзакции с изменением данных.
+func (p *Postgres) Enqueue(ctx context.Context, db Execer, body []byte) error {
+	_, err := db.Exec(ctx, "insert into accrual_jobs(payload) values($1)", string(body))
+	return err
+}
+
+// EnqueueBatch ставит задания в очередь одним запросом через переданное соединение.
+func (p *Postgres) EnqueueBatch(ctx context.Context, db Execer, bodies [][]byte) error {
+	payloads := make([]string, 0, len(bodies))
+	for _, body := range bodies {
+		payloads = append(payloads, string(body))
+	}
+
+	_, err := db.Exec(ctx, "insert into accrual_jobs(payload) select unnest($1::text[])", payloads)
+	return err
+}
+
+// PublishDelayed ставит задание в очередь с отложенным временем запуска.
+func (p *Postgres) PublishDelayed(ctx context.Context, body []byte, headers Headers, delay time.Duration) error {
+	if headers == nil {
+		headers = Headers{}
+	}
+
+	bHeaders, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.pgxpool.Exec(
+		ctx,
+		"insert into accrual_jobs(payload, headers, next_run_at) values($1, $2::jsonb, now() + $3::interval)",
+		string(body),
+		string(bHeaders),
+		delay,
+	)
+	return err
+}
+
+func (p *Postgres) Consume() (<-chan Message, error) {
+	select {
+	case <-p.done:
+		return nil, ErrClosed
+	default:
+	}
+
+	messages := make(chan Message)
+	go func() {
+		defer close(messages)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-p.done
+			cancel()
+		}()
+
+		for {
+			message, err := p.claim(ctx)
+			if ctx.Err() == nil {
+				p.setClaimError(err)
+			}
+			if err == nil && message != nil {
+				select {
+				case messages <- message:
+				case <-p.done:
+					// Задание не передано обработчику, блокировка снимается без учета попытки
+					_ = message.Nack(true)
+					return
+				}
+
+				// Ожидание свободного обработчика сокращает блокировку, поэтому она продлевается
+				if err = p.extend(ctx, message); err != nil && ctx.Err() == nil {
+					p.logger.Warning("Failed extend accrual job lock", err)
+				}
+				continue
+			}
+
+			select {
+			case <-p.done:
+				return
+			case <-time.After(p.pollInterval):
+			}
+		}
+	}()
+
+	return messages, nil
+}
+
+// Check отчет о состоянии выборки заданий: при ошибке выборки обработка заказов остановлена.
+func (p *Postgres) Check(_ context.Context) health.Report {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.claimError == nil {
+		return health.Report{Status: health.StatusUp}
+	}
+
+	return health.Report{
+		Status: health.StatusDown,
+		Details: map[string]any{
+			"error":         p.claimError.Error(),
+			"failing_since": p.failingSince.Format(time.RFC3339),
+		},
+	}
+}
+
+func (p *Postgres) DeadLetters(ctx context.Context, limit int) ([]*DeadLetter, error) {
+	rows, err := p.pgxpool.Query(
+		ctx,
+		`select payload, attempts, coalesce(last_error, ''), failed_at from accrual_jobs
+		where failed_at is not null
+		order by failed_at desc
+		limit nullif($1, 0)`,
+		limit,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deadLetters := make([]*DeadLetter, 0)
+	for rows.Next() {
+		var payload string
+		deadLetter := &DeadLetter{}
+		err = rows.Scan(&payload, &deadLetter.Attempts, &deadLetter.LastError, &deadLetter.DeadAt)
+		if err != nil {
+			return nil, err
+		}
+		deadLetter.Body = []byte(payload)
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, rows.Err()
+}
+
+func (p *Postgres) FindDeadLetter(ctx context.Context, body []byte) (*DeadLetter, error) {
+	deadLetter := &DeadLetter{Body: body}
+	err := p.pgxpool.QueryRow(
+		ctx,
+		`select attempts, coalesce(last_error, ''), failed_at from accrual_jobs
+		where failed_at is not null and payload = $1
+		order by failed_at desc
+		limit 1`,
+		string(body),
+	).Scan(&deadLetter.Attempts, &deadLetter.LastError, &deadLetter.DeadAt)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = ErrDeadLetterNotFound
+		}
+		return nil, err
+	}
+
+	return deadLetter, nil
+}
+
+func (p *Postgres) ReplayDeadLetter(ctx context.Context, body []byte) error {
+	tag, err := p.pgxpool.Exec(
+		ctx,
+		`update accrual_jobs set failed_at = null, attempts = 0, last_error = null, headers = '{}', next_run_at = now(), locked_until = null
+		where failed_at is not null and payload = $1`,
+		string(body),
+	)
+	return deadLetterResult(tag, err)
+}
+
+func (p *Postgres) DiscardDeadLetter(ctx context.Context, body []byte) error {
+	tag, err := p.pgxpool.Exec(
+		ctx,
+		"delete from accrual_jobs where failed_at is not null and payload = $1",
+		string(body),
+	)
+	return deadLetterResult(tag, err)
+}
+
+func (p *Postgres) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+	return nil
+}
+
+// Запоминает результат выборки заданий. Ошибка логируется при первом появлении
+// и при смене текста, восстановление логируется один раз.
+func (p *Postgres) setClaimError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case err == nil && p.claimError != nil:
+		p.logger.Notice("Accrual jobs claiming restored")
+		p.failingSince = time.Time{}
+	case err != nil && p.claimError == nil:
+		p.logger.Error("Failed claim accrual jobs", err)
+		p.failingSince = time.Now()
+	case err != nil && err.Error() != p.claimError.Error():
+		p.logger.Error("Failed claim accrual jobs", err)
+	}
+
+	p.claimError = err
+}
+
+// Забирает одно задание, nil если доступных заданий нет.
+func (p *Postgres) claim(ctx context.Context) (*postgresMessage, error) {
+	var payload, headers string
+	message := &postgresMessage{queue: p}
+	err := p.pgxpool.QueryRow(
+		ctx,
+		`update accrual_jobs set locked_until = now() + $1::interval, attempts = attempts + 1
+		where uuid = (
+			select uuid from accrual_jobs
+			where failed_at is null and next_run_at <= now() and (locked_until is null or locked_until < now())
+			order by next_run_at
+			limit 1
+			for update skip locked
+		)
+		returning uuid, payload, headers::text, attempts`,
+		p.visibilityTimeout,
+	).Scan(&message.uuid, &payload, &headers, &message.attempts)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	message.body = []byte(payload)
+	if err = json.Unmarshal([]byte(headers), &message.headers); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// Продлевает блокировку переданного обработчику задания. Номер попытки отличает
+// текущую выборку от повторной, а снятая блокировка означает, что задание уже обработано.
+func (p *Postgres) extend(ctx context.Context, message *postgresMessage) error {
+	_, err := p.pgxpool.Exec(
+		ctx,
+		`update accrual_jobs set locked_until = now() + $1::interval
+		where uuid = $2 and attempts = $3 and failed_at is null and locked_until is not null`,
+		p.visibilityTimeout,
+		message.uuid,
+		message.attempts,
+	)
+	return err
+}
+
+func (pm *postgresMessage) Body() []byte {
+	return pm.body
+}
+
+func (pm *postgresMessage) Headers() Headers {
+	return pm.headers
+}
+
+func (pm *postgresMessage) Ack() error {
+	_, err := pm.queue.pgxpool.Exec(
+		context.Background(),
+		"delete from accrual_jobs where uuid = $1",
+		pm.uuid,
+	)
+	return err
+}
+
+// Nack с возвратом в очередь снимает блокировку задания, не засчитывая попытку.
+func (pm *postgresMessage) Nack(requeue bool) error {
+	if !requeue {
+		return pm.NackWithError(false, nil)
+	}
+
+	_, err := pm.queue.pgxpool.Exec(
+		context.Background(),
+		"update accrual_jobs set locked_until = null, attempts = greatest(attempts - 1, 0) where uuid = $1",
+		pm.uuid,
+	)
+	return err
+}
+
+// NackWithError откладывает повторную попытку с экспоненциально растущей задержкой.
+// Без возврата в очередь или после исчерпания попыток задание помечается как окончательно неудавшееся.
+func (pm *postgresMessage) NackWithError(requeue bool, cause error) error {
+	var lastError *string
+	if cause != nil {
+		message := cause.Error()
+		lastError = &message
+	}
+
+	if !requeue || pm.queue.maxAttempts > 0 && pm.attempts >= pm.queue.maxAttempts {
+		_, err := pm.queue.pgxpool.Exec(
+			context.Background(),
+			"update accrual_jobs set locked_until = null, failed_at = now(), last_error = coalesce($1, last_error) where uuid = $2",
+			lastError,
+			pm.uuid,
+		)
+		return err
+	}
+
+	_, err := pm.queue.pgxpool.Exec(
+		context.Background(),
+		"update accrual_jobs set locked_until = null, next_run_at = now() + $1::interval, last_error = coalesce($2, last_error) where uuid = $3",
+		retryDelay(pm.attempts),
+		lastError,
+		pm.uuid,
+	)
+	return err
+}
+
+func deadLetterResult(tag pgconn.CommandTag, err error) error {
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/internal/service/health"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+func TestPostgres_CheckReportsClaimErrors(t *testing.T) {
+	q := NewPostgres(nil, time.Minute, time.Second, 5, logger.New())
+
+	if report := q.Check(context.Background()); report.Status != health.StatusUp {
+		t.Errorf("initial status = %s, want %s", report.Status, health.StatusUp)
+	}
+
+	q.setClaimError(errors.New("connection refused"))
+	report := q.Check(context.Background())
+	if report.Status != health.StatusDown || report.Details["error"] != "connection refused" {
+		t.Errorf("failing report = %+v, want %s with error", report, health.StatusDown)
+	}
+
+	q.setClaimError(nil)
+	if report = q.Check(context.Background()); report.Status != health.StatusUp {
+		t.Errorf("restored status = %s, want %s", report.Status, health.StatusUp)
+	}
+}
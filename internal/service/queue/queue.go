@@ -0,0 +1,104 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	DriverRabbitMQ = "rabbitmq"
+	DriverMemory   = "memory"
+	DriverPostgres = "postgres"
+)
+
+const maxRetryDelay = 5 * time.Minute
+
+var (
+	ErrClosed             = errors.New("queue closed")
+	ErrUnavailable        = errors.New("queue unavailable")
+	ErrUnroutable         = errors.New("message unroutable")
+	ErrNotConfirmed       = errors.New("message not confirmed by broker")
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+)
+
+// Headers метаданные сообщения, передаваемые вместе с ним при отложенной публикации.
+// Позволяют не хранить состояние обработки сообщения в памяти экземпляра приложения.
+type Headers map[string]string
+
+// Message сообщение, полученное из очереди. Каждое сообщение должно быть
+// подтверждено через Ack или возвращено через Nack.
+type Message interface {
+	Body() []byte
+	// Headers метаданные, с которыми сообщение было опубликовано, сохраняются при повторных попытках.
+	Headers() Headers
+	Ack() error
+	Nack(requeue bool) error
+}
+
+// ErrorNacker сообщение, сохраняющее причину неудачной обработки при возврате в очередь.
+// Каждый такой возврат считается попыткой, после исчерпания попыток,
+// как и при отказе от возврата, сообщение переносится в очередь недоставленных.
+type ErrorNacker interface {
+	NackWithError(requeue bool, cause error) error
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, body []byte) error
+}
+
+// DelayedPublisher публикация сообщений, доступных обработчикам только по истечении задержки.
+type DelayedPublisher interface {
+	PublishDelayed(ctx context.Context, body []byte, headers Headers, delay time.Duration) error
+}
+
+type Consumer interface {
+	Consume() (<-chan Message, error)
+}
+
+// DeadLetter сообщение, обработка которого окончательно не удалась.
+type DeadLetter struct {
+	Body      []byte
+	Attempts  int
+	LastError string
+	DeadAt    time.Time
+}
+
+// DeadLetterQueue управление очередью недоставленных сообщений,
+// сообщения идентифицируются содержимым (номером заказа).
+type DeadLetterQueue interface {
+	DeadLetters(ctx context.Context, limit int) ([]*DeadLetter, error)
+	FindDeadLetter(ctx context.Context, body []byte) (*DeadLetter, error)
+	ReplayDeadLetter(ctx context.Context, body []byte) error
+	DiscardDeadLetter(ctx context.Context, body []byte) error
+}
+
+type Queue interface {
+	Publisher
+	DelayedPublisher
+	Consumer
+	DeadLetterQueue
+	Close() error
+}
+
+// NackWithError возвращает сообщение в очередь с причиной неудачи,
+// если реализация очереди умеет ее сохранять.
+func NackWithError(message Message, requeue bool, cause error) error {
+	if nacker, ok := message.(ErrorNacker); ok {
+		return nacker.NackWithError(requeue, cause)
+	}
+	return message.Nack(requeue)
+}
+
+// Задержка повторной попытки после неудачи: 2^attempts секунд, но не более maxRetryDelay.
+func retryDelay(attempts int) time.Duration {
+	if attempts > 8 {
+		return maxRetryDelay
+	}
+
+	delay := time.Second << attempts
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
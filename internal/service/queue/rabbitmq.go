@@ -1,30 +1,788 @@
 package queue
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/casnerano/yandex-gophermart/internal/service/health"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
 )
 
+const (
+	headerRetryCount = "x-retry-count"
+	headerLastError  = "x-last-error"
+	headerDeadAt     = "x-dead-at"
+	headerDelayUntil = "x-delay-until"
+
+	// Префикс заголовков AMQP, в которых передаются Headers сообщения
+	headerPrefix = "x-header-"
+)
+
+// Уровни очередей отложенных сообщений. Задержка набирается последовательными
+// переходами через наибольший уровень, не превышающий оставшееся время.
+var delayTiers = []time.Duration{
+	time.Second,
+	5 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+}
+
+const (
+	reconnectMinDelay = 500 * time.Millisecond
+	reconnectMaxDelay = 30 * time.Second
+)
+
+// Наибольшее время ожидания публикации, выполняемой самой очередью при обработке сообщений.
+const republishTimeout = 30 * time.Second
+
+// Наибольшее количество сообщений, просматриваемых в очереди недоставленных за один раз:
+// просмотренные сообщения удерживаются неподтвержденными до конца просмотра.
+const deadLetterScanLimit = 1000
+
+// RabbitMQ очередь в брокере RabbitMQ с автоматическим восстановлением соединения.
+// На время недоступности брокера публикуемые сообщения накапливаются в памяти
+// и отправляются после переподключения, подписки обработчиков восстанавливаются.
 type RabbitMQ struct {
-	conn     *amqp.Connection
-	channel  *amqp.Channel
+	dsn              string
+	exchange         string
+	queue            string
+	prefetch         int
+	maxRetries       int
+	outageBufferSize int
+	logger           logger.Logger
+
+	mu             sync.RWMutex
+	conn           *amqp.Connection
+	channel        *amqp.Channel
+	confirms       *confirmTracker
+	connected      bool
+	ready          chan struct{}
+	lastError      error
+	disconnectedAt time.Time
+	reconnects     int
+	pending        []*pendingPublishing
+
+	// Контекст публикаций, выполняемых самой очередью, отменяется при закрытии
+	publishCtx    context.Context
+	cancelPublish context.CancelFunc
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type pendingPublishing struct {
 	exchange string
-	queue    string
+	key      string
+	body     []byte
+	headers  amqp.Table
+	result   chan error
+}
+
+// Ожидает отправки отложенного сообщения после переподключения.
+func (p *pendingPublishing) wait(ctx context.Context) error {
+	select {
+	case err := <-p.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-func NewRabbitMQ(dsn string, exchange string, queue string) (*RabbitMQ, error) {
-	conn, err := amqp.Dial(dsn)
+// NewRabbitMQ подключается к брокеру и объявляет топологию: основной обменник с очередью,
+// обменник недоставленных сообщений "<exchange>.dlx" с очередью "<queue>.dlq"
+// и очереди отложенных сообщений "<queue>.delay.<ms>", из которых по истечении TTL
+// сообщения возвращаются в основной обменник.
+// Сообщение переносится в очередь недоставленных после maxRetries неудачных попыток,
+// на время недоступности брокера в памяти сохраняется не более outageBufferSize сообщений.
+func NewRabbitMQ(
+	dsn string,
+	exchange string,
+	queue string,
+	prefetch int,
+	maxRetries int,
+	outageBufferSize int,
+	logger logger.Logger,
+) (*RabbitMQ, error) {
+	publishCtx, cancelPublish := context.WithCancel(context.Background())
+	rmq := &RabbitMQ{
+		dsn:              dsn,
+		exchange:         exchange,
+		queue:            queue,
+		prefetch:         prefetch,
+		maxRetries:       maxRetries,
+		outageBufferSize: outageBufferSize,
+		logger:           logger,
+		ready:            make(chan struct{}),
+		publishCtx:       publishCtx,
+		cancelPublish:    cancelPublish,
+		done:             make(chan struct{}),
+	}
+
+	if err := rmq.connect(); err != nil {
+		cancelPublish()
+		return nil, err
+	}
+
+	go rmq.supervise()
+
+	return rmq, nil
+}
+
+func (rmq *RabbitMQ) Publish(ctx context.Context, body []byte) error {
+	return rmq.publish(ctx, rmq.exchange, body, amqp.Table{headerRetryCount: int32(0)})
+}
+
+// PublishDelayed публикует сообщение, которое станет доступно обработчикам через delay.
+func (rmq *RabbitMQ) PublishDelayed(ctx context.Context, body []byte, headers Headers, delay time.Duration) error {
+	table := amqp.Table{headerRetryCount: int32(0)}
+	for key, value := range headers {
+		table[headerPrefix+key] = value
+	}
+	return rmq.publishDelayed(ctx, body, table, delay)
+}
+
+// Consume подписывается на основную очередь. При потере соединения подписка
+// восстанавливается после переподключения, канал сообщений закрывается только вызовом Close.
+func (rmq *RabbitMQ) Consume() (<-chan Message, error) {
+	select {
+	case <-rmq.done:
+		return nil, ErrClosed
+	default:
+	}
+
+	deliveries, err := rmq.subscribe()
 	if err != nil {
 		return nil, err
 	}
 
-	channel, err := conn.Channel()
+	messages := make(chan Message)
+	go func() {
+		defer close(messages)
+		for {
+			for delivery := range deliveries {
+				if rmq.redelay(delivery) {
+					continue
+				}
+
+				select {
+				case messages <- &rabbitMQMessage{delivery: delivery, rmq: rmq}:
+				case <-rmq.done:
+					return
+				}
+			}
+
+			// Канал доставки закрыт: соединение потеряно или очередь закрыта
+			for {
+				if !rmq.waitConnected() {
+					return
+				}
+
+				deliveries, err = rmq.subscribe()
+				if err == nil {
+					break
+				}
+
+				select {
+				case <-rmq.done:
+					return
+				case <-time.After(reconnectMinDelay):
+				}
+			}
+		}
+	}()
+
+	return messages, nil
+}
+
+// Check отчет о состоянии соединения с брокером.
+func (rmq *RabbitMQ) Check(_ context.Context) health.Report {
+	rmq.mu.RLock()
+	defer rmq.mu.RUnlock()
+
+	details := map[string]any{
+		"reconnects": rmq.reconnects,
+		"buffered":   len(rmq.pending),
+	}
+
+	if rmq.connected {
+		return health.Report{Status: health.StatusUp, Details: details}
+	}
+
+	if rmq.lastError != nil {
+		details["error"] = rmq.lastError.Error()
+	}
+	if !rmq.disconnectedAt.IsZero() {
+		details["disconnected_at"] = rmq.disconnectedAt.Format(time.RFC3339)
+	}
+
+	return health.Report{Status: health.StatusDown, Details: details}
+}
+
+func (rmq *RabbitMQ) DeadLetters(ctx context.Context, limit int) ([]*DeadLetter, error) {
+	deadLetters := make([]*DeadLetter, 0)
+	err := rmq.scanDeadLetters(ctx, limit, func(delivery amqp.Delivery) (bool, error) {
+		deadLetters = append(deadLetters, toDeadLetter(delivery))
+		return limit > 0 && len(deadLetters) >= limit, nil
+	})
+	return deadLetters, err
+}
+
+func (rmq *RabbitMQ) FindDeadLetter(ctx context.Context, body []byte) (*DeadLetter, error) {
+	var found *DeadLetter
+	err := rmq.scanDeadLetters(ctx, 0, func(delivery amqp.Delivery) (bool, error) {
+		if bytes.Equal(delivery.Body, body) {
+			found = toDeadLetter(delivery)
+			return true, nil
+		}
+		return false, nil
+	})
+
 	if err != nil {
 		return nil, err
 	}
 
-	err = channel.ExchangeDeclare(
+	if found == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	return found, nil
+}
+
+func (rmq *RabbitMQ) ReplayDeadLetter(ctx context.Context, body []byte) error {
+	return rmq.takeDeadLetter(ctx, body, func() error {
+		return rmq.Publish(ctx, body)
+	})
+}
+
+func (rmq *RabbitMQ) DiscardDeadLetter(ctx context.Context, body []byte) error {
+	return rmq.takeDeadLetter(ctx, body, func() error {
+		return nil
+	})
+}
+
+func (rmq *RabbitMQ) Close() error {
+	var err error
+	rmq.closeOnce.Do(func() {
+		close(rmq.done)
+		rmq.cancelPublish()
+
+		rmq.mu.Lock()
+		defer rmq.mu.Unlock()
+
+		rmq.connected = false
+		for _, publishing := range rmq.pending {
+			publishing.result <- ErrClosed
+		}
+		rmq.pending = nil
+
+		if rmq.channel != nil {
+			_ = rmq.channel.Close()
+		}
+		if rmq.conn != nil {
+			err = rmq.conn.Close()
+		}
+	})
+
+	if errors.Is(err, amqp.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (rmq *RabbitMQ) publish(ctx context.Context, exchange string, body []byte, headers amqp.Table) error {
+	return rmq.publishWithKey(ctx, exchange, "", body, headers)
+}
+
+// Публикует сообщение и ожидает подтверждения брокера. При недоступности брокера
+// сообщение откладывается до переподключения, а вызывающий ожидает его подтверждения,
+// поэтому успешная публикация всегда означает, что сообщение сохранено брокером.
+func (rmq *RabbitMQ) publishWithKey(ctx context.Context, exchange, key string, body []byte, headers amqp.Table) error {
+	publishing := &pendingPublishing{
+		exchange: exchange,
+		key:      key,
+		body:     body,
+		headers:  headers,
+		result:   make(chan error, 1),
+	}
+
+	rmq.mu.Lock()
+	for rmq.connected {
+		confirms := rmq.confirms
+		rmq.mu.Unlock()
+
+		err := confirms.publish(ctx, publishing)
+		if !errors.Is(err, amqp.ErrClosed) {
+			return err
+		}
+
+		// Повтор имеет смысл, только если соединение уже восстановлено
+		rmq.mu.Lock()
+		if rmq.confirms == confirms {
+			break
+		}
+	}
+	err := rmq.buffer(publishing)
+	rmq.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err = publishing.wait(ctx); err != nil {
+		rmq.unbuffer(publishing)
+	}
+	return err
+}
+
+// Сохраняет сообщение до переподключения, вызывается под блокировкой mu.
+func (rmq *RabbitMQ) buffer(publishing *pendingPublishing) error {
+	select {
+	case <-rmq.done:
+		return ErrClosed
+	default:
+	}
+
+	if len(rmq.pending) >= rmq.outageBufferSize {
+		return ErrUnavailable
+	}
+
+	rmq.pending = append(rmq.pending, publishing)
+	return nil
+}
+
+// Убирает из накопленных сообщение, которое вызывающий перестал ожидать:
+// после ошибки публикации сообщение не должно быть отправлено.
+func (rmq *RabbitMQ) unbuffer(publishing *pendingPublishing) {
+	rmq.mu.Lock()
+	defer rmq.mu.Unlock()
+
+	for i, candidate := range rmq.pending {
+		if candidate == publishing {
+			rmq.pending = append(rmq.pending[:i], rmq.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// Контекст публикации при обработке сообщения: прерывается закрытием очереди
+// и ограничен republishTimeout, чтобы недоступность брокера не блокировала обработку.
+func (rmq *RabbitMQ) republishContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(rmq.publishCtx, republishTimeout)
+}
+
+// Подключается к брокеру, объявляет топологию и отправляет накопленные сообщения.
+func (rmq *RabbitMQ) connect() error {
+	conn, err := amqp.Dial(rmq.dsn)
+	if err != nil {
+		return err
+	}
+
+	channel, err := conn.Channel()
+	if err == nil {
+		err = rmq.declareTopology(channel)
+	}
+	if err == nil {
+		err = channel.Confirm(false)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	rmq.mu.Lock()
+	select {
+	case <-rmq.done:
+		rmq.mu.Unlock()
+		_ = conn.Close()
+		return ErrClosed
+	default:
+	}
+
+	rmq.conn = conn
+	rmq.channel = channel
+	rmq.confirms = newConfirmTracker(channel)
+	rmq.connected = true
+	rmq.lastError = nil
+	rmq.disconnectedAt = time.Time{}
+	close(rmq.ready)
+	confirms := rmq.confirms
+	pending := rmq.pending
+	rmq.pending = nil
+	rmq.mu.Unlock()
+
+	for i, publishing := range pending {
+		err = confirms.publish(rmq.publishCtx, publishing)
+		if errors.Is(err, amqp.ErrClosed) || errors.Is(err, context.Canceled) {
+			rmq.mu.Lock()
+			select {
+			case <-rmq.done:
+				// Close уже завершил накопленные публикации, оставшиеся завершаются здесь
+				for _, publishing := range pending[i:] {
+					publishing.result <- ErrClosed
+				}
+			default:
+				rmq.pending = append(pending[i:], rmq.pending...)
+			}
+			rmq.mu.Unlock()
+			return nil
+		}
+		publishing.result <- err
+	}
+
+	return nil
+}
+
+// Следит за соединением и переподключается с экспоненциально растущей задержкой.
+func (rmq *RabbitMQ) supervise() {
+	for {
+		rmq.mu.RLock()
+		conn, channel := rmq.conn, rmq.channel
+		rmq.mu.RUnlock()
+
+		connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		channelClosed := channel.NotifyClose(make(chan *amqp.Error, 1))
+
+		var cause *amqp.Error
+		select {
+		case <-rmq.done:
+			return
+		case cause = <-connClosed:
+		case cause = <-channelClosed:
+		}
+
+		select {
+		case <-rmq.done:
+			return
+		default:
+		}
+
+		rmq.disconnect(conn, cause)
+
+		for delay := reconnectMinDelay; ; {
+			select {
+			case <-rmq.done:
+				return
+			case <-time.After(delay):
+			}
+
+			err := rmq.connect()
+			if err == nil {
+				rmq.mu.Lock()
+				rmq.reconnects++
+				rmq.mu.Unlock()
+
+				rmq.logger.Notice("RabbitMQ connection restored")
+				break
+			}
+
+			rmq.mu.Lock()
+			rmq.lastError = err
+			rmq.mu.Unlock()
+
+			rmq.logger.Warning("Failed reconnect to rabbitmq", err)
+			if delay *= 2; delay > reconnectMaxDelay {
+				delay = reconnectMaxDelay
+			}
+		}
+	}
+}
+
+func (rmq *RabbitMQ) disconnect(conn *amqp.Connection, cause *amqp.Error) {
+	// Канал мог быть закрыт брокером при живом соединении
+	_ = conn.Close()
+
+	rmq.mu.Lock()
+	rmq.connected = false
+	rmq.ready = make(chan struct{})
+	rmq.disconnectedAt = time.Now()
+	if cause != nil {
+		rmq.lastError = cause
+	} else {
+		rmq.lastError = amqp.ErrClosed
+	}
+	rmq.mu.Unlock()
+
+	rmq.logger.Error("RabbitMQ connection lost", rmq.lastError)
+}
+
+// Ожидает восстановления соединения, возвращает false после закрытия очереди.
+func (rmq *RabbitMQ) waitConnected() bool {
+	for {
+		rmq.mu.RLock()
+		connected, ready := rmq.connected, rmq.ready
+		rmq.mu.RUnlock()
+
+		if connected {
+			return true
+		}
+
+		select {
+		case <-rmq.done:
+			return false
+		case <-ready:
+		}
+	}
+}
+
+func (rmq *RabbitMQ) subscribe() (<-chan amqp.Delivery, error) {
+	rmq.mu.RLock()
+	channel := rmq.channel
+	rmq.mu.RUnlock()
+
+	return channel.Consume(
+		rmq.queue, // queue
+		"",        // consumer
+		false,     // auto-ack
+		false,     // exclusive
+		false,     // no-local
+		false,     // no-wait
+		nil,       // arguments
+	)
+}
+
+func (rmq *RabbitMQ) declareTopology(channel *amqp.Channel) error {
+	// Брокер не передает обработчикам больше неподтвержденных сообщений, чем они могут обработать
+	err := channel.Qos(
+		rmq.prefetch, // prefetch count
+		0,            // prefetch size
+		false,        // global
+	)
+	if err != nil {
+		return err
+	}
+
+	err = declareQueue(channel, deadLetterName(rmq.exchange, "dlx"), deadLetterName(rmq.queue, "dlq"), nil)
+	if err != nil {
+		return err
+	}
+
+	// Основная очередь объявляется без аргументов, как и до появления очереди недоставленных:
+	// брокер отклоняет повторное объявление существующей очереди с другими аргументами.
+	// Недоставленные сообщения публикуются в обменник "<exchange>.dlx" самим приложением.
+	err = declareQueue(channel, rmq.exchange, rmq.queue, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, tier := range delayTiers {
+		_, err = channel.QueueDeclare(
+			delayQueueName(rmq.queue, tier), // name
+			true,                            // durable
+			false,                           // delete when unused
+			false,                           // exclusive
+			false,                           // no-wait
+			amqp.Table{ // arguments
+				"x-message-ttl":          tier.Milliseconds(),
+				"x-dead-letter-exchange": rmq.exchange,
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Публикует сообщение через обменник по умолчанию в очередь отложенных сообщений
+// наибольшего уровня, не превышающего delay. Короткие задержки округляются до первого уровня.
+func (rmq *RabbitMQ) publishDelayed(ctx context.Context, body []byte, headers amqp.Table, delay time.Duration) error {
+	if delay <= 0 {
+		return rmq.publish(ctx, rmq.exchange, body, headers)
+	}
+
+	tier := delayTiers[0]
+	for _, candidate := range delayTiers {
+		if candidate <= delay {
+			tier = candidate
+		}
+	}
+
+	headers[headerDelayUntil] = time.Now().Add(delay).Format(time.RFC3339Nano)
+	return rmq.publishWithKey(ctx, "", delayQueueName(rmq.queue, tier), body, headers)
+}
+
+// Возвращает в очередь отложенных сообщений сообщение, задержка которого еще не истекла.
+func (rmq *RabbitMQ) redelay(delivery amqp.Delivery) bool {
+	value, ok := delivery.Headers[headerDelayUntil].(string)
+	if !ok {
+		return false
+	}
+
+	delayUntil, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return false
+	}
+
+	remaining := time.Until(delayUntil)
+	if remaining < delayTiers[0] {
+		return false
+	}
+
+	headers := amqp.Table{}
+	for key, value := range delivery.Headers {
+		headers[key] = value
+	}
+
+	ctx, cancel := rmq.republishContext()
+	defer cancel()
+
+	if err = rmq.publishDelayed(ctx, delivery.Body, headers, remaining); err != nil {
+		return false
+	}
+
+	_ = delivery.Ack(false)
+	return true
+}
+
+// Просматривает не более limit сообщений очереди недоставленных на отдельном канале без подтверждения
+// (без ограничения не более deadLetterScanLimit). По окончании просмотра все полученные сообщения,
+// кроме подтвержденных в visit, сразу возвращаются в очередь.
+func (rmq *RabbitMQ) scanDeadLetters(ctx context.Context, limit int, visit func(delivery amqp.Delivery) (bool, error)) error {
+	if limit <= 0 || limit > deadLetterScanLimit {
+		limit = deadLetterScanLimit
+	}
+
+	rmq.mu.RLock()
+	conn := rmq.conn
+	rmq.mu.RUnlock()
+
+	channel, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	var lastTag uint64
+	defer func() {
+		if lastTag > 0 {
+			_ = channel.Nack(lastTag, true, true)
+		}
+	}()
+
+	for read := 0; read < limit; read++ {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		delivery, ok, err := channel.Get(deadLetterName(rmq.queue, "dlq"), false)
+		if err != nil || !ok {
+			return err
+		}
+		lastTag = delivery.DeliveryTag
+
+		stop, err := visit(delivery)
+		if err != nil || stop {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Извлекает сообщение из очереди недоставленных, если action выполнен успешно.
+func (rmq *RabbitMQ) takeDeadLetter(ctx context.Context, body []byte, action func() error) error {
+	found := false
+	err := rmq.scanDeadLetters(ctx, 0, func(delivery amqp.Delivery) (bool, error) {
+		if !bytes.Equal(delivery.Body, body) {
+			return false, nil
+		}
+
+		found = true
+		if err := action(); err != nil {
+			return true, err
+		}
+		return true, delivery.Ack(false)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return ErrDeadLetterNotFound
+	}
+
+	return nil
+}
+
+type rabbitMQMessage struct {
+	delivery amqp.Delivery
+	rmq      *RabbitMQ
+}
+
+func (m *rabbitMQMessage) Body() []byte {
+	return m.delivery.Body
+}
+
+func (m *rabbitMQMessage) Headers() Headers {
+	headers := Headers{}
+	for key, value := range m.delivery.Headers {
+		if name := strings.TrimPrefix(key, headerPrefix); name != key {
+			if value, ok := value.(string); ok {
+				headers[name] = value
+			}
+		}
+	}
+	return headers
+}
+
+func (m *rabbitMQMessage) Ack() error {
+	return m.delivery.Ack(false)
+}
+
+// Nack с возвратом в очередь не считается попыткой: сообщение возвращается в очередь как есть.
+// Без возврата сообщение переносится в очередь недоставленных.
+func (m *rabbitMQMessage) Nack(requeue bool) error {
+	if !requeue {
+		return m.NackWithError(false, nil)
+	}
+	return m.delivery.Nack(false, true)
+}
+
+// NackWithError публикует копию сообщения с увеличенным счетчиком попыток и причиной неудачи
+// в очередь отложенных сообщений или, после исчерпания попыток, в обменник недоставленных.
+// Если копию опубликовать не удалось, сообщение возвращается в очередь, чтобы не быть потерянным.
+func (m *rabbitMQMessage) NackWithError(requeue bool, cause error) error {
+	retries := headerInt(m.delivery.Headers, headerRetryCount) + 1
+	headers := amqp.Table{headerRetryCount: int32(retries)}
+	for key, value := range m.Headers() {
+		headers[headerPrefix+key] = value
+	}
+	if cause != nil {
+		headers[headerLastError] = cause.Error()
+	} else if lastError, ok := m.delivery.Headers[headerLastError].(string); ok {
+		headers[headerLastError] = lastError
+	}
+
+	ctx, cancel := m.rmq.republishContext()
+	defer cancel()
+
+	var err error
+	if !requeue || m.rmq.maxRetries > 0 && retries >= m.rmq.maxRetries {
+		headers[headerDeadAt] = time.Now().Format(time.RFC3339)
+		err = m.rmq.publish(ctx, deadLetterName(m.rmq.exchange, "dlx"), m.delivery.Body, headers)
+	} else {
+		err = m.rmq.publishDelayed(ctx, m.delivery.Body, headers, retryDelay(retries))
+	}
+
+	if err != nil {
+		return m.delivery.Nack(false, true)
+	}
+
+	return m.delivery.Ack(false)
+}
+
+func declareQueue(channel *amqp.Channel, exchange, queue string, arguments amqp.Table) error {
+	err := channel.ExchangeDeclare(
 		exchange, // name
 		"fanout", // type
 		true,     // durable
@@ -34,70 +792,66 @@ func NewRabbitMQ(dsn string, exchange string, queue string) (*RabbitMQ, error) {
 		nil,      // arguments
 	)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	_, err = channel.QueueDeclare(
-		queue, // name
-		true,  // durable
-		false, // delete when unused
-		false, // exclusive
-		false, // no-wait
-		nil,   // arguments
+		queue,     // name
+		true,      // durable
+		false,     // delete when unused
+		false,     // exclusive
+		false,     // no-wait
+		arguments, // arguments
 	)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	err = channel.QueueBind(
+	return channel.QueueBind(
 		queue,    // queue name
 		"",       // routing key
 		exchange, // exchange
 		false,    // no-wait
 		nil,      // arguments
 	)
-	if err != nil {
-		return nil, err
-	}
+}
 
-	return &RabbitMQ{
-		conn:     conn,
-		channel:  channel,
-		exchange: exchange,
-		queue:    queue,
-	}, nil
+func deadLetterName(name, suffix string) string {
+	return name + "." + suffix
 }
 
-func (rmq *RabbitMQ) Publish(ctx context.Context, body []byte) error {
-	return rmq.channel.PublishWithContext(
-		ctx,
-		rmq.exchange, // exchange
-		"",           // routing key
-		false,        // mandatory
-		false,        // immediate
-		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        body,
-		},
-	)
+func delayQueueName(queue string, delay time.Duration) string {
+	return fmt.Sprintf("%s.delay.%d", queue, delay.Milliseconds())
 }
 
-func (rmq *RabbitMQ) Consume() (<-chan amqp.Delivery, error) {
-	return rmq.channel.Consume(
-		rmq.queue, // queue
-		"",        // consumer
-		false,     // auto-ack
-		false,     // exclusive
-		false,     // no-local
-		false,     // no-wait
-		nil,       // arguments
-	)
+func toDeadLetter(delivery amqp.Delivery) *DeadLetter {
+	deadLetter := &DeadLetter{
+		Body:     delivery.Body,
+		Attempts: headerInt(delivery.Headers, headerRetryCount),
+	}
+
+	if lastError, ok := delivery.Headers[headerLastError].(string); ok {
+		deadLetter.LastError = lastError
+	}
+
+	if deadAt, ok := delivery.Headers[headerDeadAt].(string); ok {
+		deadLetter.DeadAt, _ = time.Parse(time.RFC3339, deadAt)
+	} else if !delivery.Timestamp.IsZero() {
+		deadLetter.DeadAt = delivery.Timestamp
+	}
+
+	return deadLetter
 }
 
-func (rmq *RabbitMQ) Close() error {
-	err := rmq.channel.Close()
-	if err != nil {
-		return err
+func headerInt(headers amqp.Table, key string) int {
+	switch value := headers[key].(type) {
+	case int32:
+		return int(value)
+	case int64:
+		return int(value)
+	case int:
+		return value
+	default:
+		return 0
 	}
-	return rmq.conn.Close()
 }
@@ -0,0 +1,136 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// confirmTracker ожидание подтверждений публикации на канале в режиме confirm.
+//
+// Брокер отправляет basic.return для немаршрутизируемого сообщения раньше его basic.ack,
+// а клиент доставляет их последовательно. Поэтому возвраты читаются из небуферизованного
+// канала в том же цикле, что и подтверждения: к моменту обработки подтверждения
+// возврат того же сообщения уже учтен.
+type confirmTracker struct {
+	mu      sync.Mutex
+	channel *amqp.Channel
+	pending map[uint64]chan error
+	closed  bool
+}
+
+func newConfirmTracker(channel *amqp.Channel) *confirmTracker {
+	tracker := &confirmTracker{
+		channel: channel,
+		pending: make(map[uint64]chan error),
+	}
+
+	confirms := channel.NotifyPublish(make(chan amqp.Confirmation, 64))
+	returns := channel.NotifyReturn(make(chan amqp.Return))
+	go tracker.run(confirms, returns)
+
+	return tracker
+}
+
+// publish отправляет сообщение и ожидает его подтверждения брокером.
+// Сообщения сохраняются на диске брокера и должны попасть хотя бы в одну очередь.
+func (t *confirmTracker) publish(ctx context.Context, publishing *pendingPublishing) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return amqp.ErrClosed
+	}
+
+	// Номер публикации и отправка должны выполняться атомарно относительно других публикаций
+	seq := t.channel.GetNextPublishSeqNo()
+	result := make(chan error, 1)
+	t.pending[seq] = result
+
+	err := t.channel.PublishWithContext(
+		ctx,
+		publishing.exchange, // exchange
+		publishing.key,      // routing key
+		true,                // mandatory
+		false,               // immediate
+		amqp.Publishing{
+			Headers:      publishing.headers,
+			ContentType:  "text/plain",
+			DeliveryMode: amqp.Persistent,
+			MessageId:    strconv.FormatUint(seq, 10),
+			Timestamp:    time.Now(),
+			Body:         publishing.body,
+		},
+	)
+	if err != nil {
+		delete(t.pending, seq)
+		t.mu.Unlock()
+		return err
+	}
+	t.mu.Unlock()
+
+	select {
+	case err = <-result:
+		return err
+	case <-ctx.Done():
+		t.mu.Lock()
+		delete(t.pending, seq)
+		t.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (t *confirmTracker) run(confirms <-chan amqp.Confirmation, returns <-chan amqp.Return) {
+	returned := make(map[uint64]struct{})
+	for {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			if seq, err := strconv.ParseUint(ret.MessageId, 10, 64); err == nil {
+				returned[seq] = struct{}{}
+			}
+		case confirmation, ok := <-confirms:
+			if !ok {
+				t.close()
+				return
+			}
+
+			var err error
+			if _, ok = returned[confirmation.DeliveryTag]; ok {
+				delete(returned, confirmation.DeliveryTag)
+				err = ErrUnroutable
+			} else if !confirmation.Ack {
+				err = ErrNotConfirmed
+			}
+			t.resolve(confirmation.DeliveryTag, err)
+		}
+	}
+}
+
+func (t *confirmTracker) resolve(seq uint64, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if result, ok := t.pending[seq]; ok {
+		delete(t.pending, seq)
+		result <- err
+	}
+}
+
+// Завершает ожидающие публикации при закрытии канала: их судьба неизвестна,
+// поэтому они будут повторены после переподключения.
+func (t *confirmTracker) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	for seq, result := range t.pending {
+		delete(t.pending, seq)
+		result <- amqp.ErrClosed
+	}
+}
@@ -0,0 +1,130 @@
+package reconciliation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/internal/service/health"
+	"github.com/casnerano/yandex-gophermart/internal/service/queue"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+var ErrInvalidMaxAge = errors.New("reconciliation max age must be positive")
+
+// Reconciler повторно ставит в очередь начислений заказы, зависшие в статусах NEW и PROCESSING,
+// например, из-за потерянного сообщения или неизвестного ответа системы начислений.
+type Reconciler struct {
+	orders    repository.Order
+	publisher queue.Publisher
+	maxAge    time.Duration
+	interval  time.Duration
+	batchSize int
+	logger    logger.Logger
+
+	mu             sync.RWMutex
+	lastRunAt      time.Time
+	lastRecovered  int
+	totalRecovered int
+}
+
+func New(
+	orders repository.Order,
+	publisher queue.Publisher,
+	maxAge time.Duration,
+	interval time.Duration,
+	batchSize int,
+	logger logger.Logger,
+) *Reconciler {
+	return &Reconciler{
+		orders:    orders,
+		publisher: publisher,
+		maxAge:    maxAge,
+		interval:  interval,
+		batchSize: batchSize,
+		logger:    logger,
+	}
+}
+
+// Run выполняет один проход сверки и возвращает количество восстановленных заказов.
+// Заказы публикуются в транзакции их отбора: заказ, который не удалось опубликовать,
+// остается устаревшим и будет отобран при следующем проходе.
+func (r *Reconciler) Run(ctx context.Context) (int, error) {
+	// С нулевым возрастом отобранные заказы сразу снова становятся устаревшими и проход не завершается
+	if r.maxAge <= 0 {
+		return 0, ErrInvalidMaxAge
+	}
+
+	recovered := 0
+	defer func() {
+		r.mu.Lock()
+		r.lastRunAt = time.Now()
+		r.lastRecovered = recovered
+		r.totalRecovered += recovered
+		r.mu.Unlock()
+	}()
+
+	for {
+		claimed, err := r.orders.ClaimStale(ctx, r.maxAge, r.batchSize, func(order *model.Order) error {
+			return r.publisher.Publish(ctx, []byte(order.Number))
+		})
+		recovered += claimed
+		if err != nil {
+			return recovered, err
+		}
+
+		if claimed < r.batchSize {
+			break
+		}
+	}
+
+	if recovered > 0 {
+		r.logger.Warning(fmt.Sprintf("Reconciliation re-enqueued %d stale orders", recovered))
+	}
+
+	return recovered, nil
+}
+
+func (r *Reconciler) Start(ctx context.Context) {
+	if r.interval <= 0 || r.batchSize <= 0 || r.maxAge <= 0 {
+		r.logger.Warning("Orders reconciliation disabled: interval, batch size or max age is not set")
+		return
+	}
+
+	r.logger.Info("Started orders reconciliation")
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				r.logger.Info("Stopped orders reconciliation")
+				return
+			case <-ticker.C:
+				if _, err := r.Run(ctx); err != nil {
+					r.logger.Error("Failed orders reconciliation", err)
+				}
+			}
+		}
+	}()
+}
+
+func (r *Reconciler) Check(_ context.Context) health.Report {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	details := map[string]any{
+		"last_recovered":  r.lastRecovered,
+		"total_recovered": r.totalRecovered,
+	}
+	if !r.lastRunAt.IsZero() {
+		details["last_run_at"] = r.lastRunAt.Format(time.RFC3339)
+	}
+
+	return health.Report{Status: health.StatusUp, Details: details}
+}
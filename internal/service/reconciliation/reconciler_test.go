@@ -0,0 +1,97 @@
+package reconciliation
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/casnerano/yandex-gophermart/internal/model"
+	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/pkg/logger"
+)
+
+// Заказы с признаком устаревания, отбор повторяет контракт ClaimStale.
+type staleOrders struct {
+	repository.Order
+	stale map[string]bool
+}
+
+func (s *staleOrders) ClaimStale(_ context.Context, _ time.Duration, limit int, handle func(order *model.Order) error) (int, error) {
+	numbers := make([]string, 0, len(s.stale))
+	for number, stale := range s.stale {
+		if stale {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Strings(numbers)
+	if len(numbers) > limit {
+		numbers = numbers[:limit]
+	}
+
+	handled := 0
+	for _, number := range numbers {
+		if err := handle(&model.Order{Number: number}); err != nil {
+			return handled, err
+		}
+		s.stale[number] = false
+		handled++
+	}
+	return handled, nil
+}
+
+type publisher struct {
+	failAfter int
+	published []string
+}
+
+func (p *publisher) Publish(_ context.Context, body []byte) error {
+	if p.failAfter >= 0 && len(p.published) >= p.failAfter {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, string(body))
+	return nil
+}
+
+func TestReconciler_RunPartialPublishFailure(t *testing.T) {
+	orders := &staleOrders{stale: map[string]bool{"1": true, "2": true, "3": true, "4": true, "5": true}}
+	pub := &publisher{failAfter: 3}
+	reconciler := New(orders, pub, time.Minute, time.Minute, 2, logger.New())
+
+	recovered, err := reconciler.Run(context.Background())
+	if err == nil {
+		t.Fatal("Run() error = nil, want publish error")
+	}
+	if recovered != 3 {
+		t.Errorf("recovered = %d, want 3", recovered)
+	}
+
+	// Неопубликованные заказы остаются устаревшими и отбираются следующим проходом
+	for number, stale := range orders.stale {
+		if want := number > "3"; stale != want {
+			t.Errorf("order %s stale = %v, want %v", number, stale, want)
+		}
+	}
+
+	pub.failAfter = -1
+	recovered, err = reconciler.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if recovered != 2 {
+		t.Errorf("recovered after failure = %d, want 2", recovered)
+	}
+	if len(pub.published) != 5 {
+		t.Errorf("published = %v, want 5 orders", pub.published)
+	}
+}
+
+func TestReconciler_RunRejectsNonPositiveMaxAge(t *testing.T) {
+	orders := &staleOrders{stale: map[string]bool{"1": true}}
+	reconciler := New(orders, &publisher{failAfter: -1}, 0, time.Minute, 1, logger.New())
+
+	if _, err := reconciler.Run(context.Background()); !errors.Is(err, ErrInvalidMaxAge) {
+		t.Errorf("Run() error = %v, want %v", err, ErrInvalidMaxAge)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/casnerano/yandex-gophermart/internal/model"
 	"github.com/casnerano/yandex-gophermart/internal/repository"
+	"github.com/casnerano/yandex-gophermart/pkg/money"
 )
 
 type Withdraw struct {
@@ -16,7 +17,7 @@ func New(users repository.User, withdraws repository.Withdraw) *Withdraw {
 	return &Withdraw{users: users, withdraws: withdraws}
 }
 
-func (w *Withdraw) Add(ctx context.Context, orderNumber string, amount float64, userUUID string) (*model.Withdraw, error) {
+func (w *Withdraw) Add(ctx context.Context, orderNumber string, amount money.Money, userUUID string) (*model.Withdraw, error) {
 	return w.withdraws.Add(ctx, orderNumber, amount, userUUID)
 }
 
@@ -24,6 +25,14 @@ func (w *Withdraw) FindAllByUserUUID(ctx context.Context, userUUID string) ([]*m
 	return w.withdraws.FindAllByUserUUID(ctx, userUUID)
 }
 
-func (w *Withdraw) TotalWithdrawnByUserUUID(ctx context.Context, userUUID string) (float64, error) {
+func (w *Withdraw) FindPageByUserUUID(
+	ctx context.Context,
+	userUUID string,
+	page repository.Page,
+) ([]*model.Withdraw, *repository.Cursor, error) {
+	return w.withdraws.FindPageByUserUUID(ctx, userUUID, page)
+}
+
+func (w *Withdraw) TotalWithdrawnByUserUUID(ctx context.Context, userUUID string) (money.Money, error) {
 	return w.withdraws.TotalWithdrawnByUserUUID(ctx, userUUID)
 }
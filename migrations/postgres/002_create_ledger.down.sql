@@ -0,0 +1,4 @@
+drop table if exists ledger;
+drop function if exists ledger_immutable();
+drop type if exists ledger_account;
+drop type if exists ledger_entry_kind;
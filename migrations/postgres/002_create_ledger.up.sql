@@ -0,0 +1,52 @@
+create type ledger_entry_kind as enum ('ACCRUAL', 'WITHDRAWAL', 'ADJUSTMENT', 'REVERSAL');
+
+create type ledger_account as enum ('USER', 'ACCRUAL', 'WITHDRAWALS', 'ADJUSTMENTS');
+
+create table if not exists ledger (
+    uuid uuid primary key default uuid_generate_v4() not null,
+    entry_uuid uuid not null,
+    account ledger_account not null,
+    kind ledger_entry_kind not null,
+    amount decimal(12, 2) not null,
+    user_uuid uuid not null,
+    reference varchar(255),
+    reversal_of uuid,
+    created_at timestamp default now() not null,
+    constraint ledger_unique_entry_account unique (entry_uuid, account),
+    constraint ledger_unique_reversal unique (reversal_of, account),
+    constraint ledger_fk_user foreign key (user_uuid) references users (uuid)
+);
+
+create index if not exists ledger_user_account_idx on ledger (user_uuid, account);
+
+create or replace function ledger_immutable() returns trigger as $$
+begin
+    raise exception 'ledger entries are immutable';
+end;
+$$ language plpgsql;
+
+create trigger ledger_immutable_trigger
+    before update or delete on ledger
+    for each row execute function ledger_immutable();
+
+-- Перенос истории начислений и списаний, накопленной до появления журнала
+insert into ledger (entry_uuid, account, kind, amount, user_uuid, reference, created_at)
+select uuid, 'USER', 'ACCRUAL', accrual, user_uuid, number, uploaded_at from orders where accrual <> 0
+union all
+select uuid, 'ACCRUAL', 'ACCRUAL', -accrual, user_uuid, number, uploaded_at from orders where accrual <> 0
+union all
+select uuid, 'USER', 'WITHDRAWAL', -amount, user_uuid, order_number, processed_at from withdraws
+union all
+select uuid, 'WITHDRAWALS', 'WITHDRAWAL', amount, user_uuid, order_number, processed_at from withdraws;
+
+with diff as (
+    select uuid_generate_v4() as entry_uuid, u.uuid as user_uuid, coalesce(u.balance, 0) - coalesce(sum(l.amount), 0) as amount
+    from users u
+    left join ledger l on l.user_uuid = u.uuid and l.account = 'USER'
+    group by u.uuid, u.balance
+    having coalesce(u.balance, 0) - coalesce(sum(l.amount), 0) <> 0
+)
+insert into ledger (entry_uuid, account, kind, amount, user_uuid, reference)
+select entry_uuid, 'USER', 'ADJUSTMENT', amount, user_uuid, 'migration' from diff
+union all
+select entry_uuid, 'ADJUSTMENTS', 'ADJUSTMENT', -amount, user_uuid, 'migration' from diff;
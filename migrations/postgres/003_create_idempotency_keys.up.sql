@@ -0,0 +1,13 @@
+create table if not exists idempotency_keys (
+    key varchar(255) not null,
+    user_uuid uuid not null,
+    fingerprint varchar(64) not null,
+    status_code integer,
+    response_body bytea,
+    created_at timestamp default now() not null,
+    expires_at timestamp not null,
+    constraint idempotency_keys_pk primary key (user_uuid, key),
+    constraint idempotency_keys_fk_user foreign key (user_uuid) references users (uuid)
+);
+
+create index if not exists idempotency_keys_expires_at_idx on idempotency_keys (expires_at);
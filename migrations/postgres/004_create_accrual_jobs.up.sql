@@ -0,0 +1,12 @@
+create table if not exists accrual_jobs (
+    uuid uuid primary key default uuid_generate_v4() not null,
+    payload varchar(255) not null,
+    attempts integer default 0 not null,
+    next_run_at timestamp default now() not null,
+    locked_until timestamp,
+    last_error text,
+    failed_at timestamp,
+    created_at timestamp default now() not null
+);
+
+create index if not exists accrual_jobs_next_run_at_idx on accrual_jobs (next_run_at) where failed_at is null;
@@ -0,0 +1,12 @@
+create table if not exists outbox (
+    uuid uuid primary key default uuid_generate_v4() not null,
+    topic varchar(100) not null,
+    payload bytea not null,
+    attempts integer default 0 not null,
+    last_error text,
+    next_attempt_at timestamp default now() not null,
+    created_at timestamp default now() not null,
+    sent_at timestamp
+);
+
+create index if not exists outbox_pending_idx on outbox (next_attempt_at) where sent_at is null;
@@ -0,0 +1,3 @@
+drop index if exists orders_pending_updated_at_idx;
+
+alter table orders drop column if exists updated_at;
@@ -0,0 +1,5 @@
+alter table orders add column if not exists updated_at timestamp default now() not null;
+
+update orders set updated_at = uploaded_at;
+
+create index if not exists orders_pending_updated_at_idx on orders (updated_at) where status in ('NEW', 'PROCESSING');
@@ -0,0 +1,2 @@
+drop table if exists order_status_history;
+drop type if exists order_status_source;
@@ -0,0 +1,14 @@
+create type order_status_source as enum ('POLLING', 'CALLBACK');
+
+create table if not exists order_status_history (
+    uuid uuid primary key default uuid_generate_v4() not null,
+    order_uuid uuid not null,
+    status status not null,
+    accrual decimal(10, 2) default 0 not null,
+    source order_status_source not null,
+    response_code integer,
+    created_at timestamp default now() not null,
+    constraint order_status_history_fk_order foreign key (order_uuid) references orders (uuid) on delete cascade
+);
+
+create index if not exists order_status_history_order_idx on order_status_history (order_uuid, created_at);
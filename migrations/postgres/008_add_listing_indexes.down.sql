@@ -0,0 +1,2 @@
+drop index if exists withdraws_user_processed_at_idx;
+drop index if exists orders_user_uploaded_at_idx;
@@ -0,0 +1,2 @@
+create index if not exists orders_user_uploaded_at_idx on orders (user_uuid, uploaded_at, uuid);
+create index if not exists withdraws_user_processed_at_idx on withdraws (user_uuid, processed_at, uuid);
@@ -0,0 +1 @@
+drop sequence if exists user_events_seq;
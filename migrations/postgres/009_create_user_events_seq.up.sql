@@ -0,0 +1 @@
+create sequence if not exists user_events_seq;
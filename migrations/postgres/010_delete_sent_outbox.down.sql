@@ -0,0 +1,5 @@
+drop index if exists outbox_next_attempt_at_idx;
+
+alter table outbox add column if not exists sent_at timestamp;
+
+create index if not exists outbox_pending_idx on outbox (next_attempt_at) where sent_at is null;
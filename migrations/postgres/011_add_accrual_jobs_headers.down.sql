@@ -0,0 +1 @@
+alter table accrual_jobs drop column if exists headers;
@@ -0,0 +1 @@
+alter table accrual_jobs add column if not exists headers jsonb default '{}' not null;
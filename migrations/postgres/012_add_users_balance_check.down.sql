@@ -0,0 +1 @@
+alter table users drop constraint if exists users_balance_non_negative;
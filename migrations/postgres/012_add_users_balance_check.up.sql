@@ -0,0 +1,2 @@
+-- Ограничение проверяется для новых изменений баланса, существующие строки не перепроверяются
+alter table users add constraint users_balance_non_negative check (balance >= 0) not valid;
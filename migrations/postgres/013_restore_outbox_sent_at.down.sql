@@ -0,0 +1,9 @@
+delete from outbox where sent_at is not null;
+
+drop index if exists outbox_sent_at_idx;
+
+drop index if exists outbox_pending_idx;
+
+alter table outbox drop column if exists sent_at;
+
+create index if not exists outbox_next_attempt_at_idx on outbox (next_attempt_at);
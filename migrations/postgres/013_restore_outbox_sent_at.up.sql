@@ -0,0 +1,7 @@
+alter table outbox add column if not exists sent_at timestamp;
+
+drop index if exists outbox_next_attempt_at_idx;
+
+create index if not exists outbox_pending_idx on outbox (next_attempt_at) where sent_at is null;
+
+create index if not exists outbox_sent_at_idx on outbox (sent_at) where sent_at is not null;
@@ -0,0 +1,180 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Scale количество минорных единиц в одной основной.
+const Scale = 100
+
+var (
+	ErrInvalidFormat = errors.New("invalid money format")
+	ErrOverflow      = errors.New("money value overflow")
+)
+
+// Money денежная сумма в минорных единицах (сотых долях балла).
+//
+// Значения с точностью выше сотых округляются по правилу
+// "половина от нуля": 0.005 -> 0.01, -0.005 -> -0.01.
+type Money int64
+
+func FromMinor(minor int64) Money {
+	return Money(minor)
+}
+
+func Parse(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidFormat
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, hasDot := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" || hasDot && fracPart == "" || !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, ErrInvalidFormat
+	}
+
+	var units int64
+	if intPart != "" {
+		var err error
+		units, err = strconv.ParseInt(intPart, 10, 64)
+		if err != nil || units >= maxUnits {
+			return 0, ErrOverflow
+		}
+	}
+
+	var minor int64
+	for i := 0; i < 2; i++ {
+		minor *= 10
+		if i < len(fracPart) {
+			minor += int64(fracPart[i] - '0')
+		}
+	}
+
+	if len(fracPart) > 2 && fracPart[2] >= '5' {
+		minor++
+	}
+
+	value := units*Scale + minor
+	if negative {
+		value = -value
+	}
+
+	return Money(value), nil
+}
+
+func (m Money) Minor() int64 {
+	return int64(m)
+}
+
+func (m Money) IsZero() bool {
+	return m == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m < 0
+}
+
+// String возвращает сумму с двумя знаками после точки, например "500.50".
+func (m Money) String() string {
+	sign := ""
+	value := int64(m)
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, value/Scale, value%Scale)
+}
+
+// MarshalJSON сериализует сумму числом без незначащих нулей, например 500.5.
+func (m Money) MarshalJSON() ([]byte, error) {
+	s := m.String()
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	return []byte(s), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return ErrInvalidFormat
+		}
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	value, err := Parse(strings.Trim(s, `"`))
+	if err != nil {
+		return err
+	}
+
+	*m = value
+	return nil
+}
+
+// ScanNumeric реализует pgtype.NumericScanner для колонок типа decimal.
+func (m *Money) ScanNumeric(n pgtype.Numeric) error {
+	if !n.Valid {
+		*m = 0
+		return nil
+	}
+
+	if n.NaN || n.InfinityModifier != pgtype.Finite {
+		return ErrInvalidFormat
+	}
+
+	value := new(big.Int).Set(n.Int)
+	exp := int64(n.Exp) + 2
+	if exp > 0 {
+		value.Mul(value, new(big.Int).Exp(big.NewInt(10), big.NewInt(exp), nil))
+	} else if exp < 0 {
+		divisor := new(big.Int).Exp(big.NewInt(10), big.NewInt(-exp), nil)
+		remainder := new(big.Int)
+		value.QuoRem(value, divisor, remainder)
+		if remainder.Abs(remainder).Mul(remainder, big.NewInt(2)).Cmp(divisor) >= 0 {
+			value.Add(value, big.NewInt(int64(n.Int.Sign())))
+		}
+	}
+
+	if !value.IsInt64() {
+		return ErrOverflow
+	}
+
+	*m = Money(value.Int64())
+	return nil
+}
+
+// NumericValue реализует pgtype.NumericValuer, значение передается без потери точности.
+func (m Money) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(int64(m)), Exp: -2, Valid: true}, nil
+}
+
+const maxUnits = (1<<63 - 1) / Scale
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package money
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Money
+		wantErr bool
+	}{
+		{"integer", "500", 50000, false},
+		{"one fractional digit", "500.5", 50050, false},
+		{"two fractional digits", "729.98", 72998, false},
+		{"leading dot", ".5", 50, false},
+		{"negative", "-0.01", -1, false},
+		{"round half up", "0.005", 1, false},
+		{"round down", "0.0049", 0, false},
+		{"round half away from zero", "-0.005", -1, false},
+		{"empty", "", 0, true},
+		{"trailing dot", "1.", 0, true},
+		{"letters", "1a.00", 0, true},
+		{"overflow", "99999999999999999999", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoney_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		value Money
+		want  string
+	}{
+		{"zero", 0, "0"},
+		{"integer", 50000, "500"},
+		{"one fractional digit", 50050, "500.5"},
+		{"two fractional digits", 72998, "729.98"},
+		{"cents", 5, "0.05"},
+		{"negative", -150, "-1.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.value.MarshalJSON()
+			if err != nil {
+				t.Fatalf("MarshalJSON() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("MarshalJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoney_ScanNumeric(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     pgtype.Numeric
+		want    Money
+		wantErr bool
+	}{
+		{"null", pgtype.Numeric{}, 0, false},
+		{"decimal", pgtype.Numeric{Int: big.NewInt(50050), Exp: -2, Valid: true}, 50050, false},
+		{"small negative", pgtype.Numeric{Int: big.NewInt(-5), Exp: -2, Valid: true}, -5, false},
+		{"positive exponent", pgtype.Numeric{Int: big.NewInt(3), Exp: 1, Valid: true}, 3000, false},
+		{"round half away from zero", pgtype.Numeric{Int: big.NewInt(-1005), Exp: -3, Valid: true}, -101, false},
+		{"nan", pgtype.Numeric{NaN: true, Valid: true}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Money
+			err := got.ScanNumeric(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ScanNumeric() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ScanNumeric() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}